@@ -23,6 +23,13 @@ func main() {
 			return err
 		}
 
+		gatewayStack, err := pulumi.NewStackReference(ctx, "mirrorboards/mirrorboards-platform-gateway/dev", nil)
+		if err != nil {
+			return err
+		}
+		gatewayName := gatewayStack.GetStringOutput(pulumi.String("GatewayName"))
+		gatewayNamespace := gatewayStack.GetStringOutput(pulumi.String("GatewayNamespace"))
+
 		appLabels := pulumi.StringMap{
 			"app": pulumi.String("nginx"),
 		}
@@ -95,8 +102,8 @@ func main() {
 					},
 					"parentRefs": pulumi.Array{
 						pulumi.Map{
-							"name":        pulumi.String("mirrorboards-platform-gateway"),
-							"namespace":   pulumi.String("aks-istio-ingress"),
+							"name":        gatewayName,
+							"namespace":   gatewayNamespace,
 							"sectionName": pulumi.String("https"),
 						},
 					},
@@ -117,6 +124,45 @@ func main() {
 			return err
 		}
 
+		_, err = apiextensions.NewCustomResource(ctx, nsName+"-route-redirect", &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("gateway.networking.k8s.io/v1"),
+			Kind:       pulumi.String("HTTPRoute"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("nginx-redirect"),
+				Namespace: pulumi.String(nsName),
+			},
+			OtherFields: map[string]interface{}{
+				"spec": pulumi.Map{
+					"hostnames": pulumi.StringArray{
+						pulumi.String("test.mirrorboards.network"),
+					},
+					"parentRefs": pulumi.Array{
+						pulumi.Map{
+							"name":        gatewayName,
+							"namespace":   gatewayNamespace,
+							"sectionName": pulumi.String("http"),
+						},
+					},
+					"rules": pulumi.Array{
+						pulumi.Map{
+							"filters": pulumi.Array{
+								pulumi.Map{
+									"type": pulumi.String("RequestRedirect"),
+									"requestRedirect": pulumi.Map{
+										"scheme":     pulumi.String("https"),
+										"statusCode": pulumi.Int(301),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{ns}))
+		if err != nil {
+			return err
+		}
+
 		ctx.Export("Namespace", pulumi.String(nsName))
 
 		return nil
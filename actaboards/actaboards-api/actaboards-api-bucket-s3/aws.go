@@ -0,0 +1,490 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// parseOidcProviderArn splits an IAM OIDC provider ARN
+// (arn:aws:iam::<account>:oidc-provider/<issuer>) into account ID and issuer.
+func parseOidcProviderArn(arn string) (string, string, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "iam" || !strings.HasPrefix(parts[5], "oidc-provider/") {
+		return "", "", fmt.Errorf("s3:oidcProviderArn %q is not an IAM OIDC provider ARN", arn)
+	}
+
+	return parts[4], strings.TrimPrefix(parts[5], "oidc-provider/"), nil
+}
+
+// newAwsBucket provisions one bucket on AWS through the Crossplane upbound provider,
+// together with its optional replica and upload queue.
+func newAwsBucket(ctx *pulumi.Context, bucket bucketConfig) (*bucketOutputs, error) {
+	awsCfg := config.New(ctx, "aws")
+	awsRegion := awsCfg.Require("region")
+
+	bucketName := bucket.Name
+	public := bucket.PublicAccess == accessPublicRead
+
+	result := &bucketOutputs{
+		Name:            bucketName,
+		EndpointUrl:     fmt.Sprintf("https://s3.%s.amazonaws.com", awsRegion),
+		Region:          awsRegion,
+		PublicUrlPrefix: fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucketName, awsRegion),
+	}
+
+	// --- S3 Bucket resources ---
+
+	name, alias := bucket.resourceName("s3", "bucket")
+	s3Bucket, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta2"),
+		Kind:       pulumi.String("Bucket"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(bucketName),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"region": pulumi.String(awsRegion),
+				},
+			},
+		},
+	}, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Resource = s3Bucket
+
+	// Public buckets allow object ACLs (public-read uploads); private ones block them entirely
+	name, alias = bucket.resourceName("s3", "public-access-block")
+	publicAccessBlock, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("BucketPublicAccessBlock"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(bucketName + "-public-access"),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"region":                pulumi.String(awsRegion),
+					"blockPublicAcls":       pulumi.Bool(!public),
+					"blockPublicPolicy":     pulumi.Bool(!public),
+					"ignorePublicAcls":      pulumi.Bool(!public),
+					"restrictPublicBuckets": pulumi.Bool(!public),
+					"bucketRef": pulumi.Map{
+						"name": pulumi.String(bucketName),
+					},
+				},
+			},
+		},
+	}, alias, pulumi.DependsOn([]pulumi.Resource{s3Bucket}))
+	if err != nil {
+		return nil, err
+	}
+
+	objectOwnership := "BucketOwnerEnforced"
+	if public {
+		objectOwnership = "BucketOwnerPreferred"
+	}
+
+	name, alias = bucket.resourceName("s3", "ownership-controls")
+	ownershipControls, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("BucketOwnershipControls"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(bucketName + "-ownership"),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"region": pulumi.String(awsRegion),
+					"bucketRef": pulumi.Map{
+						"name": pulumi.String(bucketName),
+					},
+					"rule": pulumi.MapArray{
+						pulumi.Map{
+							"objectOwnership": pulumi.String(objectOwnership),
+						},
+					},
+				},
+			},
+		},
+	}, alias, pulumi.DependsOn([]pulumi.Resource{s3Bucket}))
+	if err != nil {
+		return nil, err
+	}
+
+	if bucket.Cors != nil {
+		name, alias = bucket.resourceName("s3", "cors")
+		_, err = apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
+			Kind:       pulumi.String("BucketCorsConfiguration"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String(bucketName + "-cors"),
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"forProvider": pulumi.Map{
+						"region": pulumi.String(awsRegion),
+						"bucketRef": pulumi.Map{
+							"name": pulumi.String(bucketName),
+						},
+						"corsRule": pulumi.MapArray{
+							corsRule(bucket.Cors),
+						},
+					},
+				},
+			},
+		}, alias, pulumi.DependsOn([]pulumi.Resource{s3Bucket, publicAccessBlock, ownershipControls}))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(bucket.Lifecycle) > 0 {
+		rules := pulumi.MapArray{}
+		for _, rule := range bucket.Lifecycle {
+			awsRule := pulumi.Map{
+				"id":     pulumi.String(rule.Id),
+				"status": pulumi.String("Enabled"),
+				"filter": pulumi.MapArray{
+					pulumi.Map{
+						"prefix": pulumi.String(rule.Prefix),
+					},
+				},
+			}
+			if rule.ExpirationDays > 0 {
+				awsRule["expiration"] = pulumi.MapArray{
+					pulumi.Map{"days": pulumi.Int(rule.ExpirationDays)},
+				}
+			}
+			if rule.NoncurrentVersionExpirationDays > 0 {
+				awsRule["noncurrentVersionExpiration"] = pulumi.MapArray{
+					pulumi.Map{"noncurrentDays": pulumi.Int(rule.NoncurrentVersionExpirationDays)},
+				}
+			}
+			if rule.AbortIncompleteUploadDays > 0 {
+				awsRule["abortIncompleteMultipartUpload"] = pulumi.MapArray{
+					pulumi.Map{"daysAfterInitiation": pulumi.Int(rule.AbortIncompleteUploadDays)},
+				}
+			}
+			rules = append(rules, awsRule)
+		}
+
+		name, alias = bucket.resourceName("s3", "lifecycle")
+		_, err = apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
+			Kind:       pulumi.String("BucketLifecycleConfiguration"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String(bucketName + "-lifecycle"),
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"forProvider": pulumi.Map{
+						"region": pulumi.String(awsRegion),
+						"bucketRef": pulumi.Map{
+							"name": pulumi.String(bucketName),
+						},
+						"rule": rules,
+					},
+				},
+			},
+		}, alias, pulumi.DependsOn([]pulumi.Resource{s3Bucket}))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// --- Optional cross-region replica for disaster recovery ---
+
+	if bucket.ReplicaRegion != "" {
+		if bucket.ReplicaRegion == awsRegion {
+			return nil, fmt.Errorf("replica region of bucket %q must differ from aws:region %q", bucket.Key, awsRegion)
+		}
+
+		replicaBucketName, err := newAwsReplica(ctx, bucket, awsRegion, s3Bucket)
+		if err != nil {
+			return nil, err
+		}
+
+		result.ReplicaBucketName = replicaBucketName
+		result.ReplicaRegion = bucket.ReplicaRegion
+		result.ReplicaEndpointUrl = fmt.Sprintf("https://s3.%s.amazonaws.com", bucket.ReplicaRegion)
+	}
+
+	// --- Optional s3:ObjectCreated:* notifications to SQS for async upload processing ---
+
+	if bucket.Notifications != nil {
+		queue := uploadQueueFor(bucketName, awsRegion, awsCfg.Require("accountId"))
+
+		err = newAwsNotifications(ctx, bucket, queue, awsRegion, s3Bucket)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Queue = queue
+	}
+
+	return result, nil
+}
+
+// corsRule converts a bucket's CORS config into a Crossplane corsRule entry.
+func corsRule(cors *corsConfig) pulumi.Map {
+	rule := pulumi.Map{
+		"allowedOrigins": pulumi.ToStringArray(cors.AllowedOrigins),
+		"allowedMethods": pulumi.ToStringArray(cors.AllowedMethods),
+		"allowedHeaders": pulumi.ToStringArray(cors.AllowedHeaders),
+	}
+	if cors.MaxAgeSeconds > 0 {
+		rule["maxAgeSeconds"] = pulumi.Int(cors.MaxAgeSeconds)
+	}
+
+	return rule
+}
+
+// newAwsConsumer creates the IAM principal of a consumer, scoped to its buckets and their
+// upload queues, with either a static access key or an IRSA role for its ServiceAccount.
+func newAwsConsumer(ctx *pulumi.Context, s3Cfg *config.Config, namespaceName pulumi.StringOutput, consumer consumerConfig, buckets []*bucketOutputs, identity string) (*consumerCredentials, error) {
+	prefix := consumer.prefix
+	result := &consumerCredentials{}
+
+	var dependsOn []pulumi.Resource
+	var bucketResources []string
+	var queueResources []string
+
+	for _, bucket := range buckets {
+		dependsOn = append(dependsOn, bucket.Resource)
+		bucketResources = append(bucketResources, "arn:aws:s3:::"+bucket.Name, "arn:aws:s3:::"+bucket.Name+"/*")
+		if bucket.Queue != nil {
+			queueResources = append(queueResources, bucket.Queue.Arn)
+		}
+	}
+
+	// --- IAM policy scoped to the consumer's buckets ---
+
+	statements := []map[string]any{
+		{
+			"Effect": "Allow",
+			"Action": []string{
+				"s3:GetObject",
+				"s3:PutObject",
+				"s3:PutObjectAcl",
+				"s3:DeleteObject",
+				"s3:ListBucket",
+				"s3:GetBucketLocation",
+			},
+			"Resource": bucketResources,
+		},
+	}
+
+	// Workers consume the upload queues with the same credentials as the buckets
+	if len(queueResources) > 0 {
+		statements = append(statements, map[string]any{
+			"Effect": "Allow",
+			"Action": []string{
+				"sqs:ReceiveMessage",
+				"sqs:DeleteMessage",
+				"sqs:ChangeMessageVisibility",
+				"sqs:GetQueueAttributes",
+				"sqs:GetQueueUrl",
+			},
+			"Resource": queueResources,
+		})
+	}
+
+	policyDocument, err := json.MarshalIndent(map[string]any{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	name, alias := consumer.resourceName("iam", "policy")
+	iamPolicy, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("Policy"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(prefix + "-s3-policy"),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"policy": pulumi.String(string(policyDocument)),
+				},
+			},
+		},
+	}, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	switch identity {
+	case identityAccessKey:
+		// --- IAM User with static access key ---
+
+		iamUserName := prefix + "-s3-user"
+		crossplaneSecretName := prefix + "-s3-creds"
+
+		name, alias = consumer.resourceName("iam", "user")
+		iamUser, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
+			Kind:       pulumi.String("User"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String(iamUserName),
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"forProvider": pulumi.Map{},
+				},
+			},
+		}, alias, pulumi.DependsOn(dependsOn))
+		if err != nil {
+			return nil, err
+		}
+
+		name, alias = consumer.resourceName("iam", "policy-attachment")
+		_, err = apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
+			Kind:       pulumi.String("UserPolicyAttachment"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String(prefix + "-s3-policy-attachment"),
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"forProvider": pulumi.Map{
+						"policyArnRef": pulumi.Map{
+							"name": pulumi.String(prefix + "-s3-policy"),
+						},
+						"userRef": pulumi.Map{
+							"name": pulumi.String(iamUserName),
+						},
+					},
+				},
+			},
+		}, alias, pulumi.DependsOn([]pulumi.Resource{iamUser, iamPolicy}))
+		if err != nil {
+			return nil, err
+		}
+
+		// AccessKey — Crossplane writes credentials to Secret in external-secrets-store namespace
+		name, alias = consumer.resourceName("iam", "access-key")
+		_, err = apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
+			Kind:       pulumi.String("AccessKey"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String(prefix + "-s3-access-key"),
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"forProvider": pulumi.Map{
+						"userRef": pulumi.Map{
+							"name": pulumi.String(iamUserName),
+						},
+					},
+					"writeConnectionSecretToRef": pulumi.Map{
+						"name":      pulumi.String(crossplaneSecretName),
+						"namespace": pulumi.String("external-secrets-store"),
+					},
+				},
+			},
+		}, alias, pulumi.DependsOn([]pulumi.Resource{iamUser}))
+		if err != nil {
+			return nil, err
+		}
+
+		// Crossplane AccessKey writes keys: "attribute.id" (access key ID), "attribute.secret" (secret key)
+		// Verify with: kubectl get secret <crossplaneSecretName> -n external-secrets-store -o jsonpath='{.data}' | jq
+		result.CredentialsSecretName = crossplaneSecretName
+		result.AccessKeyIdProperty = "attribute.id"
+		result.SecretAccessKeyProperty = "attribute.secret"
+
+	case identityWorkload:
+		// --- IAM Role assumed by the consumer's ServiceAccount through the cluster OIDC provider (IRSA) ---
+
+		oidcProviderArn := s3Cfg.Require("oidcProviderArn")
+
+		accountId, oidcIssuer, err := parseOidcProviderArn(oidcProviderArn)
+		if err != nil {
+			return nil, err
+		}
+
+		roleName := prefix + "-s3-role"
+		result.RoleArn = fmt.Sprintf("arn:aws:iam::%s:role/%s", accountId, roleName)
+
+		trustPolicy := namespaceName.ApplyT(func(namespace string) string {
+			return fmt.Sprintf(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "Federated": "%s"
+      },
+      "Action": "sts:AssumeRoleWithWebIdentity",
+      "Condition": {
+        "StringEquals": {
+          "%s:sub": "system:serviceaccount:%s:%s",
+          "%s:aud": "sts.amazonaws.com"
+        }
+      }
+    }
+  ]
+}`, oidcProviderArn, oidcIssuer, namespace, consumer.ServiceAccountName, oidcIssuer)
+		}).(pulumi.StringOutput)
+
+		name, alias = consumer.resourceName("iam", "role")
+		iamRole, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
+			Kind:       pulumi.String("Role"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String(roleName),
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"forProvider": pulumi.Map{
+						"assumeRolePolicy": trustPolicy,
+					},
+				},
+			},
+		}, alias, pulumi.DependsOn(dependsOn))
+		if err != nil {
+			return nil, err
+		}
+
+		name, alias = consumer.resourceName("iam", "role-policy-attachment")
+		_, err = apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
+			Kind:       pulumi.String("RolePolicyAttachment"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String(prefix + "-s3-role-policy-attachment"),
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"forProvider": pulumi.Map{
+						"policyArnRef": pulumi.Map{
+							"name": pulumi.String(prefix + "-s3-policy"),
+						},
+						"roleRef": pulumi.Map{
+							"name": pulumi.String(roleName),
+						},
+					},
+				},
+			},
+		}, alias, pulumi.DependsOn([]pulumi.Resource{iamRole, iamPolicy}))
+		if err != nil {
+			return nil, err
+		}
+
+		result.ServiceAccountName = consumer.ServiceAccountName
+	}
+
+	return result, nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Public access modes a bucket can be created with.
+const (
+	accessPublicRead = "public-read"
+	accessPrivate    = "private"
+)
+
+// corsConfig is a single CORS rule applied to a bucket.
+type corsConfig struct {
+	AllowedOrigins []string `json:"allowedOrigins"`
+	AllowedMethods []string `json:"allowedMethods"`
+	AllowedHeaders []string `json:"allowedHeaders"`
+	MaxAgeSeconds  int      `json:"maxAgeSeconds"`
+}
+
+// lifecycleRule expires objects under Prefix; zero values leave that part of the rule out.
+type lifecycleRule struct {
+	Id                              string `json:"id"`
+	Prefix                          string `json:"prefix"`
+	ExpirationDays                  int    `json:"expirationDays"`
+	NoncurrentVersionExpirationDays int    `json:"noncurrentVersionExpirationDays"`
+	AbortIncompleteUploadDays       int    `json:"abortIncompleteUploadDays"`
+}
+
+// notificationsConfig sends s3:ObjectCreated:* events for Prefixes (whole bucket when empty) to a queue.
+type notificationsConfig struct {
+	Prefixes []string `json:"prefixes"`
+}
+
+// bucketConfig is one entry of s3:buckets.
+type bucketConfig struct {
+	// Key names the bucket within the stack (secret and resource names), Name is the bucket itself.
+	Key           string               `json:"key"`
+	Name          string               `json:"name"`
+	PublicAccess  string               `json:"publicAccess"`
+	Cors          *corsConfig          `json:"cors"`
+	Lifecycle     []lifecycleRule      `json:"lifecycle"`
+	ReplicaRegion string               `json:"replicaRegion"`
+	Notifications *notificationsConfig `json:"notifications"`
+
+	index int
+}
+
+// consumerConfig is one entry of s3:consumers, an IAM principal scoped to its Buckets.
+type consumerConfig struct {
+	Name               string   `json:"name"`
+	Buckets            []string `json:"buckets"`
+	ServiceAccountName string   `json:"serviceAccountName"`
+
+	index int
+	// Prefix for the physical IAM and secret names of the consumer.
+	prefix string
+}
+
+// resourceName returns the Pulumi name of a per-entry resource. The first bucket and consumer
+// are aliased to the names the stack used while it managed a single bucket, so existing
+// stacks adopt them in place instead of replacing them.
+func resourceName(index int, key string, parts ...string) (string, pulumi.ResourceOption) {
+	name := ns.Get(append([]string{parts[0], key}, parts[1:]...)...)
+
+	if index != 0 {
+		return name, pulumi.Aliases(nil)
+	}
+
+	return name, pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String(ns.Get(parts...))}})
+}
+
+func (b bucketConfig) resourceName(parts ...string) (string, pulumi.ResourceOption) {
+	return resourceName(b.index, b.Key, parts...)
+}
+
+func (c consumerConfig) resourceName(parts ...string) (string, pulumi.ResourceOption) {
+	return resourceName(c.index, c.Name, parts...)
+}
+
+// loadBuckets reads s3:buckets, falling back to the single s3:bucketName layout
+// (public-read, open CORS, s3:replicaRegion, s3:notifications) when it is not set.
+func loadBuckets(s3Cfg *config.Config) ([]bucketConfig, error) {
+	var buckets []bucketConfig
+	if err := s3Cfg.GetObject("buckets", &buckets); err != nil {
+		return nil, fmt.Errorf("s3:buckets: %w", err)
+	}
+
+	if len(buckets) == 0 {
+		var notifications *notificationsConfig
+		if s3Cfg.GetBool("notifications") {
+			notifications = &notificationsConfig{}
+			if err := s3Cfg.GetObject("notificationPrefixes", &notifications.Prefixes); err != nil {
+				return nil, fmt.Errorf("s3:notificationPrefixes must be a list of key prefixes: %w", err)
+			}
+		}
+
+		buckets = []bucketConfig{{
+			Key:          "s3",
+			Name:         s3Cfg.Require("bucketName"),
+			PublicAccess: accessPublicRead,
+			Cors: &corsConfig{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET", "PUT", "POST", "DELETE", "HEAD"},
+				AllowedHeaders: []string{"*"},
+				MaxAgeSeconds:  3600,
+			},
+			ReplicaRegion: s3Cfg.Get("replicaRegion"),
+			Notifications: notifications,
+		}}
+	}
+
+	keys := map[string]bool{}
+	names := map[string]bool{}
+
+	for i := range buckets {
+		bucket := &buckets[i]
+		bucket.index = i
+
+		if bucket.Key == "" || bucket.Name == "" {
+			return nil, fmt.Errorf("s3:buckets[%d] needs both key and name", i)
+		}
+		if keys[bucket.Key] || names[bucket.Name] {
+			return nil, fmt.Errorf("s3:buckets[%d] repeats key %q or name %q", i, bucket.Key, bucket.Name)
+		}
+		keys[bucket.Key] = true
+		names[bucket.Name] = true
+
+		if bucket.PublicAccess == "" {
+			bucket.PublicAccess = accessPrivate
+		}
+		if bucket.PublicAccess != accessPublicRead && bucket.PublicAccess != accessPrivate {
+			return nil, fmt.Errorf("s3:buckets[%d].publicAccess must be %q or %q, got %q", i, accessPublicRead, accessPrivate, bucket.PublicAccess)
+		}
+
+		for j, rule := range bucket.Lifecycle {
+			if rule.Id == "" {
+				bucket.Lifecycle[j].Id = fmt.Sprintf("rule-%d", j)
+			}
+			if rule.ExpirationDays < 0 || rule.NoncurrentVersionExpirationDays < 0 || rule.AbortIncompleteUploadDays < 0 {
+				return nil, fmt.Errorf("s3:buckets[%d].lifecycle[%d] days must not be negative", i, j)
+			}
+		}
+	}
+
+	return buckets, nil
+}
+
+// loadConsumers reads s3:consumers, defaulting to one consumer for the API with access
+// to every bucket. Each bucket must belong to exactly one consumer, whose credentials
+// end up in that bucket's secret.
+func loadConsumers(s3Cfg *config.Config, buckets []bucketConfig) ([]consumerConfig, map[string]consumerConfig, error) {
+	var consumers []consumerConfig
+	if err := s3Cfg.GetObject("consumers", &consumers); err != nil {
+		return nil, nil, fmt.Errorf("s3:consumers: %w", err)
+	}
+
+	if len(consumers) == 0 {
+		serviceAccountName := s3Cfg.Get("serviceAccountName")
+		if serviceAccountName == "" {
+			serviceAccountName = "actaboards-api"
+		}
+
+		consumer := consumerConfig{
+			Name:               "api",
+			ServiceAccountName: serviceAccountName,
+			// Keeps the IAM names the stack used for its single bucket
+			prefix: buckets[0].Name,
+		}
+		for _, bucket := range buckets {
+			consumer.Buckets = append(consumer.Buckets, bucket.Key)
+		}
+
+		consumers = []consumerConfig{consumer}
+	}
+
+	bucketKeys := map[string]bool{}
+	for _, bucket := range buckets {
+		bucketKeys[bucket.Key] = true
+	}
+
+	owners := map[string]consumerConfig{}
+
+	for i := range consumers {
+		consumer := &consumers[i]
+		consumer.index = i
+
+		if consumer.Name == "" {
+			return nil, nil, fmt.Errorf("s3:consumers[%d] needs a name", i)
+		}
+		if consumer.prefix == "" {
+			consumer.prefix = ns.Get(consumer.Name)
+		}
+		if consumer.ServiceAccountName == "" {
+			consumer.ServiceAccountName = ns.Get(consumer.Name)
+		}
+
+		for _, key := range consumer.Buckets {
+			if !bucketKeys[key] {
+				return nil, nil, fmt.Errorf("s3:consumers[%d] references unknown bucket %q", i, key)
+			}
+			if owner, ok := owners[key]; ok {
+				return nil, nil, fmt.Errorf("bucket %q is used by both consumers %q and %q", key, owner.Name, consumer.Name)
+			}
+			owners[key] = *consumer
+		}
+	}
+
+	for _, bucket := range buckets {
+		if _, ok := owners[bucket.Key]; !ok {
+			return nil, nil, fmt.Errorf("bucket %q is not used by any consumer in s3:consumers", bucket.Key)
+		}
+	}
+
+	return consumers, owners, nil
+}
@@ -11,298 +11,294 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
 )
 
+var ns = namespace.NewNamespace("actaboards", "api")
+
+// Object storage backends the bucket can be provisioned on.
+const (
+	backendAws    = "aws"
+	backendSpaces = "spaces"
+	backendMinio  = "minio"
+)
+
+// Ways the API workload can authenticate against the bucket.
+const (
+	identityAccessKey = "accessKey"
+	identityWorkload  = "workloadIdentity"
+)
+
+// bucketOutputs is what a backend hands back for one bucket's secret and stack outputs.
+type bucketOutputs struct {
+	Name            string
+	EndpointUrl     string
+	Region          string
+	PublicUrlPrefix string
+
+	// Resource the consumers' IAM principals depend on, nil when the backend creates none.
+	Resource pulumi.Resource
+
+	// Set when a cross-region replica is provisioned.
+	ReplicaBucketName  string
+	ReplicaRegion      string
+	ReplicaEndpointUrl string
+
+	// Set when upload notifications are sent to a queue.
+	Queue *uploadQueue
+}
+
+// consumerCredentials is how a consumer authenticates against its buckets.
+type consumerCredentials struct {
+	// Secret in external-secrets-store holding static credentials,
+	// empty when the workload authenticates with a role instead.
+	CredentialsSecretName   string
+	AccessKeyIdProperty     string
+	SecretAccessKeyProperty string
+
+	// Set when access is granted through workload identity.
+	RoleArn            string
+	ServiceAccountName string
+}
+
 func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
-		ns := namespace.NewNamespace("actaboards", "api")
-
-		awsCfg := config.New(ctx, "aws")
 		s3Cfg := config.New(ctx, "s3")
 
-		awsRegion := awsCfg.Require("region")
-		bucketName := s3Cfg.Require("bucketName")
-
-		endpointUrl := fmt.Sprintf("https://s3.%s.amazonaws.com", awsRegion)
-		publicUrlPrefix := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucketName, awsRegion)
+		backend := s3Cfg.Get("backend")
+		if backend == "" {
+			backend = backendAws
+		}
+		if backend != backendAws && backend != backendSpaces && backend != backendMinio {
+			return fmt.Errorf("s3:backend must be one of %q, %q, %q, got %q", backendAws, backendSpaces, backendMinio, backend)
+		}
 
-		// Get namespace from actaboards-api stack
-		apiStack, err := pulumi.NewStackReference(ctx, "mirrorboards/actaboards-api/dev", nil)
-		if err != nil {
-			return err
+		identity := s3Cfg.Get("identity")
+		if identity == "" {
+			identity = identityAccessKey
+		}
+		if identity != identityAccessKey && identity != identityWorkload {
+			return fmt.Errorf("s3:identity must be %q or %q, got %q", identityAccessKey, identityWorkload, identity)
+		}
+		if identity == identityWorkload && backend != backendAws {
+			return fmt.Errorf("s3:identity %q is only supported by the %q backend", identityWorkload, backendAws)
 		}
-		namespaceName := apiStack.GetStringOutput(pulumi.String("NamespaceName"))
 
-		// --- S3 Bucket resources ---
-
-		bucket, err := apiextensions.NewCustomResource(ctx, ns.Get("s3", "bucket"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta2"),
-			Kind:       pulumi.String("Bucket"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name: pulumi.String(bucketName),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"forProvider": pulumi.Map{
-						"region": pulumi.String(awsRegion),
-					},
-				},
-			},
-		})
+		buckets, err := loadBuckets(s3Cfg)
 		if err != nil {
 			return err
 		}
 
-		publicAccessBlock, err := apiextensions.NewCustomResource(ctx, ns.Get("s3", "public-access-block"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
-			Kind:       pulumi.String("BucketPublicAccessBlock"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name: pulumi.String(bucketName + "-public-access"),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"forProvider": pulumi.Map{
-						"region":                pulumi.String(awsRegion),
-						"blockPublicAcls":       pulumi.Bool(false),
-						"blockPublicPolicy":     pulumi.Bool(false),
-						"ignorePublicAcls":      pulumi.Bool(false),
-						"restrictPublicBuckets": pulumi.Bool(false),
-						"bucketRef": pulumi.Map{
-							"name": pulumi.String(bucketName),
-						},
-					},
-				},
-			},
-		}, pulumi.DependsOn([]pulumi.Resource{bucket}))
+		consumers, owners, err := loadConsumers(s3Cfg, buckets)
 		if err != nil {
 			return err
 		}
 
-		ownershipControls, err := apiextensions.NewCustomResource(ctx, ns.Get("s3", "ownership-controls"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
-			Kind:       pulumi.String("BucketOwnershipControls"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name: pulumi.String(bucketName + "-ownership"),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"forProvider": pulumi.Map{
-						"region": pulumi.String(awsRegion),
-						"bucketRef": pulumi.Map{
-							"name": pulumi.String(bucketName),
-						},
-						"rule": pulumi.MapArray{
-							pulumi.Map{
-								"objectOwnership": pulumi.String("BucketOwnerPreferred"),
-							},
-						},
-					},
-				},
-			},
-		}, pulumi.DependsOn([]pulumi.Resource{bucket}))
-		if err != nil {
-			return err
+		if backend != backendAws {
+			for _, bucket := range buckets {
+				if bucket.ReplicaRegion != "" || bucket.Notifications != nil {
+					return fmt.Errorf("bucket %q: replicas and notifications are only supported by the %q backend", bucket.Key, backendAws)
+				}
+			}
 		}
 
-		_, err = apiextensions.NewCustomResource(ctx, ns.Get("s3", "cors"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
-			Kind:       pulumi.String("BucketCorsConfiguration"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name: pulumi.String(bucketName + "-cors"),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"forProvider": pulumi.Map{
-						"region": pulumi.String(awsRegion),
-						"bucketRef": pulumi.Map{
-							"name": pulumi.String(bucketName),
-						},
-						"corsRule": pulumi.MapArray{
-							pulumi.Map{
-								"allowedOrigins": pulumi.ToStringArray([]string{"*"}),
-								"allowedMethods": pulumi.ToStringArray([]string{"GET", "PUT", "POST", "DELETE", "HEAD"}),
-								"allowedHeaders": pulumi.ToStringArray([]string{"*"}),
-								"maxAgeSeconds":  pulumi.Int(3600),
-							},
-						},
-					},
-				},
-			},
-		}, pulumi.DependsOn([]pulumi.Resource{bucket, publicAccessBlock, ownershipControls}))
+		// Get namespace from actaboards-api stack
+		apiStack, err := pulumi.NewStackReference(ctx, "mirrorboards/actaboards-api/dev", nil)
 		if err != nil {
 			return err
 		}
+		namespaceName := apiStack.GetStringOutput(pulumi.String("NamespaceName"))
 
-		// --- IAM User with scoped S3 permissions ---
+		// --- Buckets ---
 
-		iamUserName := bucketName + "-s3-user"
-		crossplaneSecretName := bucketName + "-s3-creds"
+		storage := map[string]*bucketOutputs{}
 
-		iamUser, err := apiextensions.NewCustomResource(ctx, ns.Get("iam", "user"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
-			Kind:       pulumi.String("User"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name: pulumi.String(iamUserName),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"forProvider": pulumi.Map{},
-				},
-			},
-		}, pulumi.DependsOn([]pulumi.Resource{bucket}))
-		if err != nil {
-			return err
+		for _, bucket := range buckets {
+			switch backend {
+			case backendAws:
+				storage[bucket.Key], err = newAwsBucket(ctx, bucket)
+			case backendSpaces:
+				storage[bucket.Key], err = newSpacesBucket(ctx, s3Cfg, bucket)
+			case backendMinio:
+				storage[bucket.Key] = newMinioBucket(s3Cfg, bucket)
+			}
+			if err != nil {
+				return err
+			}
 		}
 
-		policyDocument := fmt.Sprintf(`{
-  "Version": "2012-10-17",
-  "Statement": [
-    {
-      "Effect": "Allow",
-      "Action": [
-        "s3:GetObject",
-        "s3:PutObject",
-        "s3:PutObjectAcl",
-        "s3:DeleteObject",
-        "s3:ListBucket",
-        "s3:GetBucketLocation"
-      ],
-      "Resource": [
-        "arn:aws:s3:::%s",
-        "arn:aws:s3:::%s/*"
-      ]
-    }
-  ]
-}`, bucketName, bucketName)
-
-		iamPolicy, err := apiextensions.NewCustomResource(ctx, ns.Get("iam", "policy"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
-			Kind:       pulumi.String("Policy"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name: pulumi.String(bucketName + "-s3-policy"),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"forProvider": pulumi.Map{
-						"policy": pulumi.String(policyDocument),
-					},
-				},
-			},
-		})
-		if err != nil {
-			return err
-		}
+		// --- Consumers: one principal each, scoped to its buckets where the backend allows it ---
 
-		_, err = apiextensions.NewCustomResource(ctx, ns.Get("iam", "policy-attachment"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
-			Kind:       pulumi.String("UserPolicyAttachment"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name: pulumi.String(bucketName + "-s3-policy-attachment"),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"forProvider": pulumi.Map{
-						"policyArnRef": pulumi.Map{
-							"name": pulumi.String(bucketName + "-s3-policy"),
-						},
-						"userRef": pulumi.Map{
-							"name": pulumi.String(iamUserName),
-						},
-					},
-				},
-			},
-		}, pulumi.DependsOn([]pulumi.Resource{iamUser, iamPolicy}))
-		if err != nil {
-			return err
-		}
+		credentials := map[string]*consumerCredentials{}
 
-		// AccessKey — Crossplane writes credentials to Secret in external-secrets-store namespace
-		_, err = apiextensions.NewCustomResource(ctx, ns.Get("iam", "access-key"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
-			Kind:       pulumi.String("AccessKey"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name: pulumi.String(bucketName + "-s3-access-key"),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"forProvider": pulumi.Map{
-						"userRef": pulumi.Map{
-							"name": pulumi.String(iamUserName),
-						},
-					},
-					"writeConnectionSecretToRef": pulumi.Map{
-						"name":      pulumi.String(crossplaneSecretName),
-						"namespace": pulumi.String("external-secrets-store"),
-					},
-				},
-			},
-		}, pulumi.DependsOn([]pulumi.Resource{iamUser}))
-		if err != nil {
-			return err
+		for _, consumer := range consumers {
+			var consumerBuckets []*bucketOutputs
+			for _, key := range consumer.Buckets {
+				if storage[key].Resource != nil {
+					consumerBuckets = append(consumerBuckets, storage[key])
+				}
+			}
+
+			switch backend {
+			case backendAws:
+				credentials[consumer.Name], err = newAwsConsumer(ctx, s3Cfg, namespaceName, consumer, consumerBuckets, identity)
+			case backendSpaces:
+				credentials[consumer.Name], err = newSpacesConsumer(ctx, consumer)
+			case backendMinio:
+				credentials[consumer.Name], err = newMinioConsumer(ctx, s3Cfg, consumer)
+			}
+			if err != nil {
+				return err
+			}
 		}
 
-		// --- ExternalSecret: merge Crossplane credentials + static values into one Secret ---
-		// Crossplane AccessKey writes keys: "attribute.id" (access key ID), "attribute.secret" (secret key)
-		// Verify with: kubectl get secret <crossplaneSecretName> -n external-secrets-store -o jsonpath='{.data}' | jq
-
-		finalSecretName := ns.Get("bucket", "s3")
-
-		externalSecret, err := apiextensions.NewCustomResource(ctx, ns.Get("external-secret"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("external-secrets.io/v1"),
-			Kind:       pulumi.String("ExternalSecret"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name:      pulumi.String(finalSecretName),
-				Namespace: namespaceName,
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"refreshInterval": pulumi.String("1h"),
-					"secretStoreRef": pulumi.Map{
-						"name": pulumi.String("kubernetes-secret-store"),
-						"kind": pulumi.String("ClusterSecretStore"),
+		// --- ExternalSecret per bucket: merge its consumer's credentials + static values into one Secret ---
+		// The contract is the same for every backend; with workload identity there are
+		// no static credentials, only bucket settings.
+
+		secretNames := pulumi.StringMap{}
+		bucketExports := pulumi.Map{}
+
+		for _, bucket := range buckets {
+			bucketStorage := storage[bucket.Key]
+			bucketCredentials := credentials[owners[bucket.Key].Name]
+
+			finalSecretName := ns.Get("bucket", bucket.Key)
+
+			secretTemplateData := pulumi.Map{
+				"endpoint_url":      pulumi.String(bucketStorage.EndpointUrl),
+				"region":            pulumi.String(bucketStorage.Region),
+				"bucket":            pulumi.String(bucketStorage.Name),
+				"public_url_prefix": pulumi.String(bucketStorage.PublicUrlPrefix),
+			}
+			secretData := pulumi.MapArray{}
+
+			if bucketStorage.Queue != nil {
+				secretTemplateData["queue_url"] = pulumi.String(bucketStorage.Queue.Url)
+			}
+
+			if bucketCredentials.CredentialsSecretName != "" {
+				secretTemplateData["access_key_id"] = pulumi.String("{{ .access_key_id }}")
+				secretTemplateData["secret_access_key"] = pulumi.String("{{ .secret_access_key }}")
+
+				secretData = append(secretData,
+					pulumi.Map{
+						"secretKey": pulumi.String("access_key_id"),
+						"remoteRef": pulumi.Map{
+							"key":      pulumi.String(bucketCredentials.CredentialsSecretName),
+							"property": pulumi.String(bucketCredentials.AccessKeyIdProperty),
+						},
 					},
-					"target": pulumi.Map{
-						"name": pulumi.String(finalSecretName),
-						"template": pulumi.Map{
-							"engineVersion": pulumi.String("v2"),
-							"data": pulumi.Map{
-								"access_key_id":     pulumi.String("{{ .access_key_id }}"),
-								"secret_access_key": pulumi.String("{{ .secret_access_key }}"),
-								"endpoint_url":      pulumi.String(endpointUrl),
-								"region":            pulumi.String(awsRegion),
-								"bucket":            pulumi.String(bucketName),
-								"public_url_prefix": pulumi.String(publicUrlPrefix),
-							},
+					pulumi.Map{
+						"secretKey": pulumi.String("secret_access_key"),
+						"remoteRef": pulumi.Map{
+							"key":      pulumi.String(bucketCredentials.CredentialsSecretName),
+							"property": pulumi.String(bucketCredentials.SecretAccessKeyProperty),
 						},
 					},
-					"data": pulumi.MapArray{
-						pulumi.Map{
-							"secretKey": pulumi.String("access_key_id"),
-							"remoteRef": pulumi.Map{
-								"key":      pulumi.String(crossplaneSecretName),
-								"property": pulumi.String("attribute.id"),
-							},
+				)
+			}
+
+			name, alias := bucket.resourceName("external-secret")
+			externalSecret, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+				ApiVersion: pulumi.String("external-secrets.io/v1"),
+				Kind:       pulumi.String("ExternalSecret"),
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:      pulumi.String(finalSecretName),
+					Namespace: namespaceName,
+				},
+				OtherFields: kubernetes.UntypedArgs{
+					"spec": pulumi.Map{
+						"refreshInterval": pulumi.String("1h"),
+						"secretStoreRef": pulumi.Map{
+							"name": pulumi.String("kubernetes-secret-store"),
+							"kind": pulumi.String("ClusterSecretStore"),
 						},
-						pulumi.Map{
-							"secretKey": pulumi.String("secret_access_key"),
-							"remoteRef": pulumi.Map{
-								"key":      pulumi.String(crossplaneSecretName),
-								"property": pulumi.String("attribute.secret"),
+						"target": pulumi.Map{
+							"name": pulumi.String(finalSecretName),
+							"template": pulumi.Map{
+								"engineVersion": pulumi.String("v2"),
+								"data":          secretTemplateData,
 							},
 						},
+						"data": secretData,
 					},
 				},
-			},
-		})
-		if err != nil {
-			return err
+			}, alias)
+			if err != nil {
+				return err
+			}
+
+			if backend == backendMinio {
+				err = newMinioBucketJob(ctx, bucket, namespaceName, finalSecretName, externalSecret)
+				if err != nil {
+					return err
+				}
+			}
+
+			secretNames[bucket.Key] = pulumi.String(finalSecretName)
+
+			bucketExport := pulumi.Map{
+				"name":            pulumi.String(bucketStorage.Name),
+				"region":          pulumi.String(bucketStorage.Region),
+				"endpoint":        pulumi.String(bucketStorage.EndpointUrl),
+				"publicUrlPrefix": pulumi.String(bucketStorage.PublicUrlPrefix),
+				"secretName":      pulumi.String(finalSecretName),
+				"consumer":        pulumi.String(owners[bucket.Key].Name),
+			}
+			if bucketStorage.ReplicaBucketName != "" {
+				bucketExport["replicaName"] = pulumi.String(bucketStorage.ReplicaBucketName)
+				bucketExport["replicaRegion"] = pulumi.String(bucketStorage.ReplicaRegion)
+				bucketExport["replicaEndpoint"] = pulumi.String(bucketStorage.ReplicaEndpointUrl)
+			}
+			if bucketStorage.Queue != nil {
+				bucketExport["queueName"] = pulumi.String(bucketStorage.Queue.Name)
+				bucketExport["queueUrl"] = pulumi.String(bucketStorage.Queue.Url)
+			}
+			bucketExports[bucket.Key] = bucketExport
+
+			// The first bucket keeps the single-bucket outputs consumers already read
+			if bucket.index != 0 {
+				continue
+			}
+
+			ctx.Export("S3SecretName", pulumi.String(finalSecretName))
+			ctx.Export("BucketName", pulumi.String(bucketStorage.Name))
+			ctx.Export("BucketRegion", pulumi.String(bucketStorage.Region))
+			ctx.Export("BucketEndpoint", pulumi.String(bucketStorage.EndpointUrl))
+			ctx.Export("BucketPublicUrlPrefix", pulumi.String(bucketStorage.PublicUrlPrefix))
+			ctx.Export("ExternalSecretName", externalSecret.Metadata.Name())
+
+			if bucketStorage.ReplicaBucketName != "" {
+				ctx.Export("ReplicaBucketName", pulumi.String(bucketStorage.ReplicaBucketName))
+				ctx.Export("ReplicaBucketRegion", pulumi.String(bucketStorage.ReplicaRegion))
+				ctx.Export("ReplicaBucketEndpoint", pulumi.String(bucketStorage.ReplicaEndpointUrl))
+			}
+
+			if bucketStorage.Queue != nil {
+				ctx.Export("QueueName", pulumi.String(bucketStorage.Queue.Name))
+				ctx.Export("QueueUrl", pulumi.String(bucketStorage.Queue.Url))
+			}
+
+			if bucketCredentials.RoleArn != "" {
+				ctx.Export("S3RoleArn", pulumi.String(bucketCredentials.RoleArn))
+				ctx.Export("S3ServiceAccountName", pulumi.String(bucketCredentials.ServiceAccountName))
+			}
 		}
 
 		// Export outputs
-		ctx.Export("S3SecretName", pulumi.String(finalSecretName))
-		ctx.Export("BucketName", bucket.Metadata.Name())
-		ctx.Export("BucketRegion", pulumi.String(awsRegion))
-		ctx.Export("BucketEndpoint", pulumi.String(endpointUrl))
-		ctx.Export("BucketPublicUrlPrefix", pulumi.String(publicUrlPrefix))
-		ctx.Export("ExternalSecretName", externalSecret.Metadata.Name())
+		ctx.Export("BucketBackend", pulumi.String(backend))
+		ctx.Export("S3SecretNames", secretNames)
+		ctx.Export("Buckets", bucketExports)
+
+		if identity == identityWorkload {
+			roleArns := pulumi.StringMap{}
+			serviceAccountNames := pulumi.StringMap{}
+			for _, consumer := range consumers {
+				roleArns[consumer.Name] = pulumi.String(credentials[consumer.Name].RoleArn)
+				serviceAccountNames[consumer.Name] = pulumi.String(credentials[consumer.Name].ServiceAccountName)
+			}
+
+			ctx.Export("S3RoleArns", roleArns)
+			ctx.Export("S3ServiceAccountNames", serviceAccountNames)
+		}
 
 		return nil
 	})
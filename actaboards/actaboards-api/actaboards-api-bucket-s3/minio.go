@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// newMinioBucket points a bucket at an existing MinIO server, e.g. a local one for
+// development. The bucket itself is created by newMinioBucketJob once its secret exists.
+// CORS is server-wide on MinIO, so the bucket's cors config is not applied.
+func newMinioBucket(s3Cfg *config.Config, bucket bucketConfig) *bucketOutputs {
+	endpointUrl := strings.TrimSuffix(s3Cfg.Require("endpoint"), "/")
+
+	publicEndpoint := strings.TrimSuffix(s3Cfg.Get("publicEndpoint"), "/")
+	if publicEndpoint == "" {
+		publicEndpoint = endpointUrl
+	}
+
+	region := s3Cfg.Get("region")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &bucketOutputs{
+		Name:            bucket.Name,
+		EndpointUrl:     endpointUrl,
+		Region:          region,
+		PublicUrlPrefix: publicEndpoint + "/" + bucket.Name,
+	}
+}
+
+// newMinioConsumer hands the configured MinIO credentials to a consumer.
+func newMinioConsumer(ctx *pulumi.Context, s3Cfg *config.Config, consumer consumerConfig) (*consumerCredentials, error) {
+	// --- MinIO credentials, stored next to the Crossplane-written secrets for the ExternalSecret ---
+
+	credentialsSecretName := consumer.prefix + "-s3-creds"
+
+	name, alias := consumer.resourceName("minio", "credentials")
+	_, err := corev1.NewSecret(ctx, name, &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(credentialsSecretName),
+			Namespace: pulumi.String("external-secrets-store"),
+		},
+		StringData: pulumi.StringMap{
+			"access_key_id":     s3Cfg.RequireSecret("accessKeyId"),
+			"secret_access_key": s3Cfg.RequireSecret("secretAccessKey"),
+		},
+	}, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	return &consumerCredentials{
+		CredentialsSecretName:   credentialsSecretName,
+		AccessKeyIdProperty:     "access_key_id",
+		SecretAccessKeyProperty: "secret_access_key",
+	}, nil
+}
+
+// minioLifecycle renders the bucket's lifecycle rules in the S3 JSON format `mc ilm import` reads.
+func minioLifecycle(rules []lifecycleRule) (string, error) {
+	minioRules := []map[string]any{}
+	for _, rule := range rules {
+		minioRule := map[string]any{
+			"ID":     rule.Id,
+			"Status": "Enabled",
+			"Filter": map[string]any{"Prefix": rule.Prefix},
+		}
+		if rule.ExpirationDays > 0 {
+			minioRule["Expiration"] = map[string]any{"Days": rule.ExpirationDays}
+		}
+		if rule.NoncurrentVersionExpirationDays > 0 {
+			minioRule["NoncurrentVersionExpiration"] = map[string]any{"NoncurrentDays": rule.NoncurrentVersionExpirationDays}
+		}
+		if rule.AbortIncompleteUploadDays > 0 {
+			minioRule["AbortIncompleteMultipartUpload"] = map[string]any{"DaysAfterInitiation": rule.AbortIncompleteUploadDays}
+		}
+		minioRules = append(minioRules, minioRule)
+	}
+
+	lifecycle, err := json.Marshal(map[string]any{"Rules": minioRules})
+
+	return string(lifecycle), err
+}
+
+// newMinioBucketJob creates the bucket and applies its access mode and lifecycle, reading
+// everything it needs from the bucket's merged S3 secret in the API namespace.
+func newMinioBucketJob(ctx *pulumi.Context, bucket bucketConfig, namespaceName pulumi.StringOutput, secretName string, externalSecret pulumi.Resource) error {
+	secretEnv := func(name string, key string) *corev1.EnvVarArgs {
+		return &corev1.EnvVarArgs{
+			Name: pulumi.String(name),
+			ValueFrom: &corev1.EnvVarSourceArgs{
+				SecretKeyRef: &corev1.SecretKeySelectorArgs{
+					Name: pulumi.String(secretName),
+					Key:  pulumi.String(key),
+				},
+			},
+		}
+	}
+
+	anonymous := "none"
+	if bucket.PublicAccess == accessPublicRead {
+		anonymous = "download"
+	}
+
+	script := `mc alias set storage "$S3_ENDPOINT_URL" "$S3_ACCESS_KEY_ID" "$S3_SECRET_ACCESS_KEY" && ` +
+		`mc mb --ignore-existing --region "$S3_REGION" "storage/$S3_BUCKET" && ` +
+		`mc anonymous set ` + anonymous + ` "storage/$S3_BUCKET"`
+
+	lifecycle := ""
+	if len(bucket.Lifecycle) > 0 {
+		var err error
+		lifecycle, err = minioLifecycle(bucket.Lifecycle)
+		if err != nil {
+			return err
+		}
+
+		script += ` && echo "$S3_LIFECYCLE" | mc ilm import "storage/$S3_BUCKET"`
+	}
+
+	name, alias := bucket.resourceName("minio", "bucket")
+	_, err := batchv1.NewJob(ctx, name, &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespaceName,
+		},
+		Spec: &batchv1.JobSpecArgs{
+			BackoffLimit: pulumi.Int(6),
+			Template: &corev1.PodTemplateSpecArgs{
+				Spec: &corev1.PodSpecArgs{
+					RestartPolicy: pulumi.String("OnFailure"),
+					Containers: corev1.ContainerArray{
+						&corev1.ContainerArgs{
+							Name:  pulumi.String("mc"),
+							Image: pulumi.String("minio/mc:latest"),
+							Command: pulumi.StringArray{
+								pulumi.String("/bin/sh"),
+								pulumi.String("-c"),
+								pulumi.String(script),
+							},
+							Env: corev1.EnvVarArray{
+								secretEnv("S3_ENDPOINT_URL", "endpoint_url"),
+								secretEnv("S3_REGION", "region"),
+								secretEnv("S3_BUCKET", "bucket"),
+								secretEnv("S3_ACCESS_KEY_ID", "access_key_id"),
+								secretEnv("S3_SECRET_ACCESS_KEY", "secret_access_key"),
+								&corev1.EnvVarArgs{
+									Name:  pulumi.String("S3_LIFECYCLE"),
+									Value: pulumi.String(lifecycle),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, alias, pulumi.DependsOn([]pulumi.Resource{externalSecret}))
+
+	return err
+}
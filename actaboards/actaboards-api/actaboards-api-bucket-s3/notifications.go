@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// uploadQueue is the SQS queue receiving s3:ObjectCreated:* events for the bucket.
+type uploadQueue struct {
+	Name string
+	Arn  string
+	Url  string
+}
+
+// uploadQueueFor names the bucket's queue; the resources are created by newAwsNotifications.
+func uploadQueueFor(bucketName string, region string, accountId string) *uploadQueue {
+	name := bucketName + "-uploads"
+
+	return &uploadQueue{
+		Name: name,
+		Arn:  fmt.Sprintf("arn:aws:sqs:%s:%s:%s", region, accountId, name),
+		Url:  fmt.Sprintf("https://sqs.%s.amazonaws.com/%s/%s", region, accountId, name),
+	}
+}
+
+// newAwsNotifications creates the SQS queue and sends s3:ObjectCreated:* events for each
+// configured prefix to it. An empty prefix list notifies for the whole bucket.
+func newAwsNotifications(ctx *pulumi.Context, bucket bucketConfig, queue *uploadQueue, region string, s3Bucket pulumi.Resource) error {
+	bucketName := bucket.Name
+	prefixes := bucket.Notifications.Prefixes
+
+	queuePolicy := fmt.Sprintf(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "Service": "s3.amazonaws.com"
+      },
+      "Action": "sqs:SendMessage",
+      "Resource": "%s",
+      "Condition": {
+        "ArnLike": {
+          "aws:SourceArn": "arn:aws:s3:::%s"
+        }
+      }
+    }
+  ]
+}`, queue.Arn, bucketName)
+
+	name, alias := bucket.resourceName("sqs", "queue")
+	sqsQueue, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("sqs.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("Queue"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(queue.Name),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"region":                   pulumi.String(region),
+					"visibilityTimeoutSeconds": pulumi.Int(300),
+					"messageRetentionSeconds":  pulumi.Int(1209600),
+					"policy":                   pulumi.String(queuePolicy),
+				},
+			},
+		},
+	}, alias)
+	if err != nil {
+		return err
+	}
+
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+
+	queueConfigurations := pulumi.MapArray{}
+	for i, prefix := range prefixes {
+		queueConfigurations = append(queueConfigurations, pulumi.Map{
+			"id":           pulumi.String(fmt.Sprintf("object-created-%d", i)),
+			"queueArn":     pulumi.String(queue.Arn),
+			"events":       pulumi.ToStringArray([]string{"s3:ObjectCreated:*"}),
+			"filterPrefix": pulumi.String(prefix),
+		})
+	}
+
+	name, alias = bucket.resourceName("s3", "notification")
+	_, err = apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("BucketNotification"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(bucketName + "-notification"),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"region": pulumi.String(region),
+					"bucketRef": pulumi.Map{
+						"name": pulumi.String(bucketName),
+					},
+					"queue": queueConfigurations,
+				},
+			},
+		},
+	}, alias, pulumi.DependsOn([]pulumi.Resource{s3Bucket, sqsQueue}))
+
+	return err
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// newBucketVersioning enables versioning on a bucket, which S3 replication requires on both sides.
+func newBucketVersioning(ctx *pulumi.Context, name string, alias pulumi.ResourceOption, bucketName string, region string, bucket pulumi.Resource) (pulumi.Resource, error) {
+	return apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("BucketVersioning"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(bucketName + "-versioning"),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"region": pulumi.String(region),
+					"bucketRef": pulumi.Map{
+						"name": pulumi.String(bucketName),
+					},
+					"versioningConfiguration": pulumi.MapArray{
+						pulumi.Map{
+							"status": pulumi.String("Enabled"),
+						},
+					},
+				},
+			},
+		},
+	}, alias, pulumi.DependsOn([]pulumi.Resource{bucket}))
+}
+
+// newAwsReplica creates a private replica bucket in the bucket's replica region and
+// replicates every object (including deletes) from the source bucket into it.
+func newAwsReplica(ctx *pulumi.Context, bucket bucketConfig, region string, s3Bucket pulumi.Resource) (string, error) {
+	bucketName := bucket.Name
+	replicaRegion := bucket.ReplicaRegion
+	replicaBucketName := bucketName + "-replica"
+	replicationRoleName := bucketName + "-s3-replication-role"
+	replicationPolicyName := bucketName + "-s3-replication-policy"
+
+	name, alias := bucket.resourceName("s3", "replica", "bucket")
+	replicaBucket, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta2"),
+		Kind:       pulumi.String("Bucket"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(replicaBucketName),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"region": pulumi.String(replicaRegion),
+				},
+			},
+		},
+	}, alias)
+	if err != nil {
+		return "", err
+	}
+
+	name, alias = bucket.resourceName("s3", "versioning")
+	sourceVersioning, err := newBucketVersioning(ctx, name, alias, bucketName, region, s3Bucket)
+	if err != nil {
+		return "", err
+	}
+
+	name, alias = bucket.resourceName("s3", "replica", "versioning")
+	replicaVersioning, err := newBucketVersioning(ctx, name, alias, replicaBucketName, replicaRegion, replicaBucket)
+	if err != nil {
+		return "", err
+	}
+
+	// --- IAM Role assumed by S3 to copy objects into the replica ---
+
+	name, alias = bucket.resourceName("iam", "replication-role")
+	replicationRole, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("Role"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(replicationRoleName),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"assumeRolePolicy": pulumi.String(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "Service": "s3.amazonaws.com"
+      },
+      "Action": "sts:AssumeRole"
+    }
+  ]
+}`),
+				},
+			},
+		},
+	}, alias)
+	if err != nil {
+		return "", err
+	}
+
+	replicationPolicyDocument := fmt.Sprintf(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "s3:GetReplicationConfiguration",
+        "s3:ListBucket"
+      ],
+      "Resource": [
+        "arn:aws:s3:::%s"
+      ]
+    },
+    {
+      "Effect": "Allow",
+      "Action": [
+        "s3:GetObjectVersionForReplication",
+        "s3:GetObjectVersionAcl",
+        "s3:GetObjectVersionTagging"
+      ],
+      "Resource": [
+        "arn:aws:s3:::%s/*"
+      ]
+    },
+    {
+      "Effect": "Allow",
+      "Action": [
+        "s3:ReplicateObject",
+        "s3:ReplicateDelete",
+        "s3:ReplicateTags"
+      ],
+      "Resource": [
+        "arn:aws:s3:::%s/*"
+      ]
+    }
+  ]
+}`, bucketName, bucketName, replicaBucketName)
+
+	name, alias = bucket.resourceName("iam", "replication-policy")
+	replicationPolicy, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("Policy"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(replicationPolicyName),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"policy": pulumi.String(replicationPolicyDocument),
+				},
+			},
+		},
+	}, alias)
+	if err != nil {
+		return "", err
+	}
+
+	name, alias = bucket.resourceName("iam", "replication-policy-attachment")
+	replicationPolicyAttachment, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("iam.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("RolePolicyAttachment"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(replicationPolicyName + "-attachment"),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"policyArnRef": pulumi.Map{
+						"name": pulumi.String(replicationPolicyName),
+					},
+					"roleRef": pulumi.Map{
+						"name": pulumi.String(replicationRoleName),
+					},
+				},
+			},
+		},
+	}, alias, pulumi.DependsOn([]pulumi.Resource{replicationRole, replicationPolicy}))
+	if err != nil {
+		return "", err
+	}
+
+	// --- Replication rule: everything in the source bucket goes to the replica ---
+
+	name, alias = bucket.resourceName("s3", "replication")
+	_, err = apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("s3.aws.upbound.io/v1beta1"),
+		Kind:       pulumi.String("BucketReplicationConfiguration"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(bucketName + "-replication"),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"region": pulumi.String(region),
+					"bucketRef": pulumi.Map{
+						"name": pulumi.String(bucketName),
+					},
+					"roleRef": pulumi.Map{
+						"name": pulumi.String(replicationRoleName),
+					},
+					"rule": pulumi.MapArray{
+						pulumi.Map{
+							"id":       pulumi.String("replicate-all"),
+							"status":   pulumi.String("Enabled"),
+							"priority": pulumi.Int(1),
+							"filter": pulumi.MapArray{
+								pulumi.Map{
+									"prefix": pulumi.String(""),
+								},
+							},
+							"deleteMarkerReplication": pulumi.MapArray{
+								pulumi.Map{
+									"status": pulumi.String("Enabled"),
+								},
+							},
+							"destination": pulumi.MapArray{
+								pulumi.Map{
+									"bucket":       pulumi.String("arn:aws:s3:::" + replicaBucketName),
+									"storageClass": pulumi.String("STANDARD"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, alias, pulumi.DependsOn([]pulumi.Resource{sourceVersioning, replicaVersioning, replicationPolicyAttachment}))
+	if err != nil {
+		return "", err
+	}
+
+	return replicaBucketName, nil
+}
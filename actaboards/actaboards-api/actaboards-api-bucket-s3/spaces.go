@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// newSpacesBucket provisions one bucket on DigitalOcean Spaces through the Crossplane
+// DigitalOcean provider.
+func newSpacesBucket(ctx *pulumi.Context, s3Cfg *config.Config, bucket bucketConfig) (*bucketOutputs, error) {
+	region := s3Cfg.Require("region")
+	bucketName := bucket.Name
+
+	result := &bucketOutputs{
+		Name:            bucketName,
+		EndpointUrl:     fmt.Sprintf("https://%s.digitaloceanspaces.com", region),
+		Region:          region,
+		PublicUrlPrefix: fmt.Sprintf("https://%s.%s.digitaloceanspaces.com", bucketName, region),
+	}
+
+	// --- Spaces Bucket resources ---
+
+	lifecycleRules := pulumi.MapArray{}
+	for _, rule := range bucket.Lifecycle {
+		spacesRule := pulumi.Map{
+			"id":      pulumi.String(rule.Id),
+			"prefix":  pulumi.String(rule.Prefix),
+			"enabled": pulumi.Bool(true),
+		}
+		if rule.ExpirationDays > 0 {
+			spacesRule["expiration"] = pulumi.MapArray{
+				pulumi.Map{"days": pulumi.Int(rule.ExpirationDays)},
+			}
+		}
+		if rule.NoncurrentVersionExpirationDays > 0 {
+			spacesRule["noncurrentVersionExpiration"] = pulumi.MapArray{
+				pulumi.Map{"days": pulumi.Int(rule.NoncurrentVersionExpirationDays)},
+			}
+		}
+		if rule.AbortIncompleteUploadDays > 0 {
+			spacesRule["abortIncompleteMultipartUploadDays"] = pulumi.Int(rule.AbortIncompleteUploadDays)
+		}
+		lifecycleRules = append(lifecycleRules, spacesRule)
+	}
+
+	name, alias := bucket.resourceName("spaces", "bucket")
+	spacesBucket, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("spaces.do.crossplane.io/v1alpha1"),
+		Kind:       pulumi.String("Bucket"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(bucketName),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"forProvider": pulumi.Map{
+					"region":        pulumi.String(region),
+					"acl":           pulumi.String(bucket.PublicAccess),
+					"lifecycleRule": lifecycleRules,
+				},
+			},
+		},
+	}, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Resource = spacesBucket
+
+	if bucket.Cors != nil {
+		name, alias = bucket.resourceName("spaces", "cors")
+		_, err = apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("spaces.do.crossplane.io/v1alpha1"),
+			Kind:       pulumi.String("BucketCorsConfiguration"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String(bucketName + "-cors"),
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"forProvider": pulumi.Map{
+						"region": pulumi.String(region),
+						"bucketRef": pulumi.Map{
+							"name": pulumi.String(bucketName),
+						},
+						"corsRule": pulumi.MapArray{
+							corsRule(bucket.Cors),
+						},
+					},
+				},
+			},
+		}, alias, pulumi.DependsOn([]pulumi.Resource{spacesBucket}))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// newSpacesConsumer hands the stack's digitalocean:spaces_access_id/spaces_secret_key to a
+// consumer. Spaces keys are account-wide, so consumers are not scoped to their buckets.
+func newSpacesConsumer(ctx *pulumi.Context, consumer consumerConfig) (*consumerCredentials, error) {
+	digitaloceanCfg := config.New(ctx, "digitalocean")
+
+	// --- Spaces credentials, stored next to the Crossplane-written secrets for the ExternalSecret ---
+
+	credentialsSecretName := consumer.prefix + "-s3-creds"
+
+	name, alias := consumer.resourceName("spaces", "credentials")
+	_, err := corev1.NewSecret(ctx, name, &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(credentialsSecretName),
+			Namespace: pulumi.String("external-secrets-store"),
+		},
+		StringData: pulumi.StringMap{
+			"access_key_id":     digitaloceanCfg.RequireSecret("spaces_access_id"),
+			"secret_access_key": digitaloceanCfg.RequireSecret("spaces_secret_key"),
+		},
+	}, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	return &consumerCredentials{
+		CredentialsSecretName:   credentialsSecretName,
+		AccessKeyIdProperty:     "access_key_id",
+		SecretAccessKeyProperty: "secret_access_key",
+	}, nil
+}
@@ -7,6 +7,9 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
+// dragonflyPort is the port the operator exposes Dragonfly's Redis protocol on.
+const dragonflyPort = 6379
+
 func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
 		ns := namespace.NewNamespace("actaboards", "api")
@@ -28,7 +31,20 @@ func main() {
 			return err
 		}
 
-		ctx.Export("DragonflyServiceName", pulumi.Sprintf("dragonfly.%s.svc.cluster.local", NamespaceName))
+		RedisHost := pulumi.Sprintf("dragonfly.%s.svc.cluster.local", NamespaceName)
+		RedisUrl := pulumi.Sprintf("redis://%s:%d", RedisHost, dragonflyPort)
+
+		ctx.Export("RedisHost", RedisHost)
+		ctx.Export("RedisPort", pulumi.Int(dragonflyPort))
+		ctx.Export("RedisUrl", RedisUrl)
+		ctx.Export("Redis", pulumi.Map{
+			"host": RedisHost,
+			"port": pulumi.Int(dragonflyPort),
+			"url":  RedisUrl,
+		})
+
+		// Bare hostname, kept for consumers that have not moved to the outputs above
+		ctx.Export("DragonflyServiceName", RedisHost)
 
 		_ = Dragonfly
 
@@ -48,13 +48,13 @@ func main() {
 
 		PostgresSecretName := postgresStack.GetStringOutput(pulumi.String("PostgresSecretName"))
 
-		// Get Redis service name from actaboards-api-db-redis stack
+		// Get Redis URL from actaboards-api-db-redis stack
 		redisStack, err := pulumi.NewStackReference(ctx, "mirrorboards/actaboards-api-db-redis/dev", nil)
 		if err != nil {
 			return err
 		}
 
-		RedisServiceName := redisStack.GetStringOutput(pulumi.String("DragonflyServiceName"))
+		RedisUrl := redisStack.GetStringOutput(pulumi.String("RedisUrl"))
 
 		// Get S3 secret name from actaboards-api-bucket-s3 stack
 		s3Stack, err := pulumi.NewStackReference(ctx, "mirrorboards/actaboards-api-bucket-s3/dev", nil)
@@ -64,6 +64,21 @@ func main() {
 
 		S3SecretName := s3Stack.GetStringOutput(pulumi.String("S3SecretName"))
 
+		// Workload identity role for S3, only exported when the bucket stack runs with s3:identity workloadIdentity
+		S3RoleArnOutput, err := s3Stack.GetOutputDetails("S3RoleArn")
+		if err != nil {
+			return err
+		}
+
+		S3RoleArn, _ := S3RoleArnOutput.Value.(string)
+
+		S3ServiceAccountNameOutput, err := s3Stack.GetOutputDetails("S3ServiceAccountName")
+		if err != nil {
+			return err
+		}
+
+		S3ServiceAccountName, _ := S3ServiceAccountNameOutput.Value.(string)
+
 		// Get Indexer Postgres secret from actaboards-indexer-db-postgres stack
 		indexerPostgresStack, err := pulumi.NewStackReference(ctx, "mirrorboards/actaboards-indexer-db-postgres/dev", nil)
 		if err != nil {
@@ -109,6 +124,170 @@ func main() {
 
 		IndexerPostgresSecretCopyName := indexerPostgresSecretCopy.Metadata.Name()
 
+		Env := corev1.EnvVarArray{
+			&corev1.EnvVarArgs{
+				Name:  pulumi.String("ENVIRONMENT"),
+				Value: pulumi.String("production"),
+			},
+			&corev1.EnvVarArgs{
+				Name:  pulumi.String("PORT"),
+				Value: pulumi.String("3000"),
+			},
+			&corev1.EnvVarArgs{
+				Name:  pulumi.String("VAULT_REDIS_CONNECTION_URL"),
+				Value: RedisUrl,
+			},
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("POSTGRES_URI"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: PostgresSecretName,
+						Key:  pulumi.String("uri"),
+					},
+				},
+			},
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("POSTGRES_HOST"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: PostgresSecretName,
+						Key:  pulumi.String("host"),
+					},
+				},
+			},
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("POSTGRES_PORT"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: PostgresSecretName,
+						Key:  pulumi.String("port"),
+					},
+				},
+			},
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("POSTGRES_DB"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: PostgresSecretName,
+						Key:  pulumi.String("dbname"),
+					},
+				},
+			},
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("POSTGRES_USER"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: PostgresSecretName,
+						Key:  pulumi.String("username"),
+					},
+				},
+			},
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("POSTGRES_PASSWORD"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: PostgresSecretName,
+						Key:  pulumi.String("password"),
+					},
+				},
+			},
+			// Indexer Postgres URI (cross-namespace secret copy)
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("INDEXER_POSTGRES_URI"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: IndexerPostgresSecretCopyName,
+						Key:  pulumi.String("uri"),
+					},
+				},
+			},
+			// S3 configuration
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("S3_ENDPOINT_URL"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: S3SecretName,
+						Key:  pulumi.String("endpoint_url"),
+					},
+				},
+			},
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("S3_REGION"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: S3SecretName,
+						Key:  pulumi.String("region"),
+					},
+				},
+			},
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("S3_BUCKET"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: S3SecretName,
+						Key:  pulumi.String("bucket"),
+					},
+				},
+			},
+			&corev1.EnvVarArgs{
+				Name: pulumi.String("S3_PUBLIC_URL_PREFIX"),
+				ValueFrom: &corev1.EnvVarSourceArgs{
+					SecretKeyRef: &corev1.SecretKeySelectorArgs{
+						Name: S3SecretName,
+						Key:  pulumi.String("public_url_prefix"),
+					},
+				},
+			},
+		}
+
+		// Static S3 credentials are only needed when the bucket stack does not grant
+		// access through a workload identity role bound to the API ServiceAccount
+		if S3RoleArn == "" {
+			Env = append(Env,
+				&corev1.EnvVarArgs{
+					Name: pulumi.String("S3_ACCESS_KEY_ID"),
+					ValueFrom: &corev1.EnvVarSourceArgs{
+						SecretKeyRef: &corev1.SecretKeySelectorArgs{
+							Name: S3SecretName,
+							Key:  pulumi.String("access_key_id"),
+						},
+					},
+				},
+				&corev1.EnvVarArgs{
+					Name: pulumi.String("S3_SECRET_ACCESS_KEY"),
+					ValueFrom: &corev1.EnvVarSourceArgs{
+						SecretKeyRef: &corev1.SecretKeySelectorArgs{
+							Name: S3SecretName,
+							Key:  pulumi.String("secret_access_key"),
+						},
+					},
+				},
+			)
+		}
+
+		// ServiceAccount annotated with the IAM role the pod assumes for S3 access
+		var ServiceAccountName pulumi.StringPtrInput
+
+		if S3RoleArn != "" {
+			ServiceAccount, err := corev1.NewServiceAccount(ctx, ns.Get("service-account"), &corev1.ServiceAccountArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:      pulumi.String(S3ServiceAccountName),
+					Namespace: NamespaceName,
+					Labels: pulumi.StringMap{
+						"app": pulumi.String("actaboards-api"),
+					},
+					Annotations: pulumi.StringMap{
+						"eks.amazonaws.com/role-arn": pulumi.String(S3RoleArn),
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			ServiceAccountName = ServiceAccount.Metadata.Name()
+		}
+
 		// Actaboards API Deployment
 		Deployment, err := appsv1.NewDeployment(ctx, ns.Get("deployment"), &appsv1.DeploymentArgs{
 			Metadata: &metav1.ObjectMetaArgs{
@@ -132,6 +311,7 @@ func main() {
 						},
 					},
 					Spec: &corev1.PodSpecArgs{
+						ServiceAccountName: ServiceAccountName,
 						ImagePullSecrets: corev1.LocalObjectReferenceArray{
 							&corev1.LocalObjectReferenceArgs{
 								Name: ImagePullSecretName,
@@ -148,141 +328,9 @@ func main() {
 										Name:          pulumi.String("http"),
 									},
 								},
-								Env: corev1.EnvVarArray{
-									&corev1.EnvVarArgs{
-										Name:  pulumi.String("ENVIRONMENT"),
-										Value: pulumi.String("production"),
-									},
-									&corev1.EnvVarArgs{
-										Name:  pulumi.String("PORT"),
-										Value: pulumi.String("3000"),
-									},
-									&corev1.EnvVarArgs{
-										Name:  pulumi.String("VAULT_REDIS_CONNECTION_URL"),
-										Value: pulumi.Sprintf("redis://%s", RedisServiceName),
-									},
-									&corev1.EnvVarArgs{
-										Name: pulumi.String("POSTGRES_URI"),
-										ValueFrom: &corev1.EnvVarSourceArgs{
-											SecretKeyRef: &corev1.SecretKeySelectorArgs{
-												Name: PostgresSecretName,
-												Key:  pulumi.String("uri"),
-											},
-										},
-									},
-									&corev1.EnvVarArgs{
-										Name: pulumi.String("POSTGRES_HOST"),
-										ValueFrom: &corev1.EnvVarSourceArgs{
-											SecretKeyRef: &corev1.SecretKeySelectorArgs{
-												Name: PostgresSecretName,
-												Key:  pulumi.String("host"),
-											},
-										},
-									},
-									&corev1.EnvVarArgs{
-										Name: pulumi.String("POSTGRES_PORT"),
-										ValueFrom: &corev1.EnvVarSourceArgs{
-											SecretKeyRef: &corev1.SecretKeySelectorArgs{
-												Name: PostgresSecretName,
-												Key:  pulumi.String("port"),
-											},
-										},
-									},
-									&corev1.EnvVarArgs{
-										Name: pulumi.String("POSTGRES_DB"),
-										ValueFrom: &corev1.EnvVarSourceArgs{
-											SecretKeyRef: &corev1.SecretKeySelectorArgs{
-												Name: PostgresSecretName,
-												Key:  pulumi.String("dbname"),
-											},
-										},
-									},
-									&corev1.EnvVarArgs{
-										Name: pulumi.String("POSTGRES_USER"),
-										ValueFrom: &corev1.EnvVarSourceArgs{
-											SecretKeyRef: &corev1.SecretKeySelectorArgs{
-												Name: PostgresSecretName,
-												Key:  pulumi.String("username"),
-											},
-										},
-									},
-									&corev1.EnvVarArgs{
-										Name: pulumi.String("POSTGRES_PASSWORD"),
-										ValueFrom: &corev1.EnvVarSourceArgs{
-											SecretKeyRef: &corev1.SecretKeySelectorArgs{
-												Name: PostgresSecretName,
-												Key:  pulumi.String("password"),
-											},
-										},
-									},
-								// Indexer Postgres URI (cross-namespace secret copy)
-								&corev1.EnvVarArgs{
-									Name: pulumi.String("INDEXER_POSTGRES_URI"),
-									ValueFrom: &corev1.EnvVarSourceArgs{
-										SecretKeyRef: &corev1.SecretKeySelectorArgs{
-											Name: IndexerPostgresSecretCopyName,
-											Key:  pulumi.String("uri"),
-										},
-									},
-								},
-								// S3 configuration
-								&corev1.EnvVarArgs{
-									Name: pulumi.String("S3_ENDPOINT_URL"),
-									ValueFrom: &corev1.EnvVarSourceArgs{
-										SecretKeyRef: &corev1.SecretKeySelectorArgs{
-											Name: S3SecretName,
-											Key:  pulumi.String("endpoint_url"),
-										},
-									},
-								},
-								&corev1.EnvVarArgs{
-									Name: pulumi.String("S3_REGION"),
-									ValueFrom: &corev1.EnvVarSourceArgs{
-										SecretKeyRef: &corev1.SecretKeySelectorArgs{
-											Name: S3SecretName,
-											Key:  pulumi.String("region"),
-										},
-									},
-								},
-								&corev1.EnvVarArgs{
-									Name: pulumi.String("S3_BUCKET"),
-									ValueFrom: &corev1.EnvVarSourceArgs{
-										SecretKeyRef: &corev1.SecretKeySelectorArgs{
-											Name: S3SecretName,
-											Key:  pulumi.String("bucket"),
-										},
-									},
-								},
-								&corev1.EnvVarArgs{
-									Name: pulumi.String("S3_PUBLIC_URL_PREFIX"),
-									ValueFrom: &corev1.EnvVarSourceArgs{
-										SecretKeyRef: &corev1.SecretKeySelectorArgs{
-											Name: S3SecretName,
-											Key:  pulumi.String("public_url_prefix"),
-										},
-									},
-								},
-								&corev1.EnvVarArgs{
-									Name: pulumi.String("S3_ACCESS_KEY_ID"),
-									ValueFrom: &corev1.EnvVarSourceArgs{
-										SecretKeyRef: &corev1.SecretKeySelectorArgs{
-											Name: S3SecretName,
-											Key:  pulumi.String("access_key_id"),
-										},
-									},
-								},
-								&corev1.EnvVarArgs{
-									Name: pulumi.String("S3_SECRET_ACCESS_KEY"),
-									ValueFrom: &corev1.EnvVarSourceArgs{
-										SecretKeyRef: &corev1.SecretKeySelectorArgs{
-											Name: S3SecretName,
-											Key:  pulumi.String("secret_access_key"),
-										},
-									},
-								},
-							},
-							Resources: &corev1.ResourceRequirementsArgs{
-								Requests: pulumi.StringMap{
+								Env: Env,
+								Resources: &corev1.ResourceRequirementsArgs{
+									Requests: pulumi.StringMap{
 										"memory": pulumi.String("128Mi"),
 										"cpu":    pulumi.String("100m"),
 									},
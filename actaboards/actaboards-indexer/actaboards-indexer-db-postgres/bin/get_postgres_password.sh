@@ -1,7 +1,7 @@
 #!/bin/bash
 
 NAMESPACE="actaboards-indexer"
-SECRET_NAME="actaboards-indexer-postgres-app"
+SECRET_NAME="$(pulumi stack output PostgresSecretName 2>/dev/null || echo actaboards-indexer-postgres-app)"
 
 echo "Password:"
 kubectl get secret "$SECRET_NAME" -n "$NAMESPACE" -o jsonpath='{.data.password}' | base64 -d
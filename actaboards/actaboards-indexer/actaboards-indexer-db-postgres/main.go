@@ -1,17 +1,54 @@
 package main
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/mirrorboards-go/mirrorboards-pulumi/namespace"
 
 	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
 	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
 	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Blue/green clusters. A reindex syncs into the idle color while the active one keeps
+// serving; postgres:active then switches PostgresSecretName over and the old color is
+// dropped from postgres:clusters to retire it.
+const (
+	colorBlue  = "blue"
+	colorGreen = "green"
 )
 
 func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
 		ns := namespace.NewNamespace("actaboards", "indexer")
+		postgresCfg := config.New(ctx, "postgres")
+
+		active := postgresCfg.Get("active")
+		if active == "" {
+			active = colorBlue
+		}
+		if active != colorBlue && active != colorGreen {
+			return fmt.Errorf("postgres:active must be %q or %q, got %q", colorBlue, colorGreen, active)
+		}
+
+		var colors []string
+		if err := postgresCfg.GetObject("clusters", &colors); err != nil {
+			return fmt.Errorf("postgres:clusters must be a list of colors: %w", err)
+		}
+		if len(colors) == 0 {
+			colors = []string{active}
+		}
+		for _, color := range colors {
+			if color != colorBlue && color != colorGreen {
+				return fmt.Errorf("postgres:clusters must only contain %q and %q, got %q", colorBlue, colorGreen, color)
+			}
+		}
+		if !slices.Contains(colors, active) {
+			return fmt.Errorf("postgres:active %q must be one of postgres:clusters %v", active, colors)
+		}
 
 		// Get namespace from actaboards-indexer stack
 		indexerStack, err := pulumi.NewStackReference(ctx, "mirrorboards/actaboards-indexer/dev", nil)
@@ -21,31 +58,51 @@ func main() {
 
 		NamespaceName := indexerStack.GetStringOutput(pulumi.String("NamespaceName"))
 
-		PostgresCluster, err := apiextensions.NewCustomResource(ctx, ns.Get("postgres"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("postgresql.cnpg.io/v1"),
-			Kind:       pulumi.String("Cluster"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name:      pulumi.String(ns.Get("postgres")),
-				Namespace: NamespaceName,
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"instances": pulumi.Int(1),
-					"storage": pulumi.Map{
-						"size": pulumi.String("1Gi"),
+		Clusters := pulumi.Map{}
+
+		for _, color := range colors {
+			// Blue is the cluster this stack has always run
+			name := ns.Get("postgres")
+			if color != colorBlue {
+				name = ns.Get("postgres", color)
+			}
+
+			PostgresCluster, err := apiextensions.NewCustomResource(ctx, name, &apiextensions.CustomResourceArgs{
+				ApiVersion: pulumi.String("postgresql.cnpg.io/v1"),
+				Kind:       pulumi.String("Cluster"),
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:      pulumi.String(name),
+					Namespace: NamespaceName,
+				},
+				OtherFields: kubernetes.UntypedArgs{
+					"spec": pulumi.Map{
+						"instances": pulumi.Int(1),
+						"storage": pulumi.Map{
+							"size": pulumi.String("1Gi"),
+						},
 					},
 				},
-			},
-		})
+			})
 
-		if err != nil {
-			return err
-		}
+			if err != nil {
+				return err
+			}
+
+			PostgresSecretName := pulumi.String(name + "-app")
 
-		PostgresSecretName := pulumi.String(ns.Get("postgres") + "-app")
+			Clusters[color] = pulumi.Map{
+				"clusterName": PostgresCluster.Metadata.Name(),
+				"secretName":  PostgresSecretName,
+			}
+
+			if color == active {
+				ctx.Export("PostgresClusterName", PostgresCluster.Metadata.Name())
+				ctx.Export("PostgresSecretName", PostgresSecretName)
+			}
+		}
 
-		ctx.Export("PostgresClusterName", PostgresCluster.Metadata.Name())
-		ctx.Export("PostgresSecretName", PostgresSecretName)
+		ctx.Export("PostgresActive", pulumi.String(active))
+		ctx.Export("PostgresClusters", Clusters)
 
 		return nil
 	})
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+
+	"github.com/mirrorboards-go/mirrorboards-pulumi/blockchain/actaboards"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Roles an indexer instance can run in, each with its own default plugins.
+const (
+	// Full node writing chain history into its Postgres database.
+	roleIndexer = "indexer"
+	// Block-producing node without history.
+	roleWitness = "witness"
+	// Node serving the chain API over RPC.
+	roleApi = "api"
+)
+
+var rolePlugins = map[string][]string{
+	roleIndexer: {"witness", "postgres_indexer"},
+	roleWitness: {"witness"},
+	roleApi:     {"witness", "api"},
+}
+
+// postgres_indexer modes: write only, query only, or both.
+const (
+	indexerModeWrite = 0
+	indexerModeQuery = 1
+	indexerModeAll   = 2
+)
+
+// indexerConfig is the indexer: config namespace. Anything not set keeps the mainnet
+// full-history node this stack has always run.
+type indexerConfig struct {
+	Image           string   `json:"image"`
+	SeedNodes       []string `json:"seedNodes"`
+	Plugins         []string `json:"plugins"`
+	Mode            int      `json:"mode"`
+	StartBlock      int      `json:"startBlock"`
+	OperationString bool     `json:"operationString"`
+	Visitor         bool     `json:"visitor"`
+}
+
+func defaultIndexerConfig() indexerConfig {
+	return indexerConfig{
+		Image: "ghcr.io/actaboards/actaboards-core:latest",
+		SeedNodes: []string{
+			"node01.acta.network:2771",
+			"node02.acta.network:2771",
+		},
+		Plugins:         []string{"witness", "postgres_indexer"},
+		Mode:            indexerModeAll,
+		StartBlock:      0,
+		OperationString: true,
+		Visitor:         true,
+	}
+}
+
+// loadIndexerConfig reads indexer:image, indexer:seedNodes, indexer:plugins, indexer:mode,
+// indexer:startBlock, indexer:operationString and indexer:visitor.
+func loadIndexerConfig(ctx *pulumi.Context) (*indexerConfig, error) {
+	indexerCfg := config.New(ctx, "indexer")
+
+	result := defaultIndexerConfig()
+
+	if image := indexerCfg.Get("image"); image != "" {
+		result.Image = image
+	}
+
+	if err := indexerCfg.GetObject("seedNodes", &result.SeedNodes); err != nil {
+		return nil, fmt.Errorf("indexer:seedNodes must be a list of host:port: %w", err)
+	}
+	if err := indexerCfg.GetObject("plugins", &result.Plugins); err != nil {
+		return nil, fmt.Errorf("indexer:plugins must be a list of plugin names: %w", err)
+	}
+
+	for _, key := range []string{"mode", "startBlock"} {
+		if indexerCfg.Get(key) == "" {
+			continue
+		}
+		value, err := indexerCfg.TryInt(key)
+		if err != nil {
+			return nil, fmt.Errorf("indexer:%s must be a number: %w", key, err)
+		}
+		if key == "mode" {
+			result.Mode = value
+		} else {
+			result.StartBlock = value
+		}
+	}
+
+	for _, key := range []string{"operationString", "visitor"} {
+		if indexerCfg.Get(key) == "" {
+			continue
+		}
+		value, err := indexerCfg.TryBool(key)
+		if err != nil {
+			return nil, fmt.Errorf("indexer:%s must be true or false: %w", key, err)
+		}
+		if key == "operationString" {
+			result.OperationString = value
+		} else {
+			result.Visitor = value
+		}
+	}
+
+	if !slices.Contains(result.Plugins, "postgres_indexer") {
+		return nil, fmt.Errorf("indexer:plugins must include postgres_indexer, got %v", result.Plugins)
+	}
+	if err := result.validate(); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// indexerInstance is one entry of indexer:instances. Fields left out fall back to the
+// top-level indexer: values, and plugins default to the role's.
+type indexerInstance struct {
+	indexerConfig
+
+	Name string `json:"name"`
+	Role string `json:"role"`
+	// Database the instance gets to itself in the indexer Postgres cluster,
+	// the shared application database when empty.
+	Database string `json:"database"`
+}
+
+// loadIndexerInstances reads indexer:instances, defaulting to the single postgres-indexer
+// this stack has always run.
+func loadIndexerInstances(ctx *pulumi.Context, defaults *indexerConfig) ([]indexerInstance, error) {
+	indexerCfg := config.New(ctx, "indexer")
+
+	var rawInstances []json.RawMessage
+	if err := indexerCfg.GetObject("instances", &rawInstances); err != nil {
+		return nil, fmt.Errorf("indexer:instances must be a list: %w", err)
+	}
+
+	if len(rawInstances) == 0 {
+		rawInstances = []json.RawMessage{json.RawMessage(`{"name": "postgres-indexer"}`)}
+	}
+
+	names := map[string]bool{}
+	instances := []indexerInstance{}
+
+	for i, rawInstance := range rawInstances {
+		instance := indexerInstance{
+			indexerConfig: *defaults,
+			Role:          roleIndexer,
+		}
+		// Plugins follow the role unless the entry lists its own
+		instance.Plugins = nil
+
+		if err := json.Unmarshal(rawInstance, &instance); err != nil {
+			return nil, fmt.Errorf("indexer:instances[%d]: %w", i, err)
+		}
+
+		if instance.Name == "" {
+			return nil, fmt.Errorf("indexer:instances[%d] needs a name", i)
+		}
+		if names[instance.Name] {
+			return nil, fmt.Errorf("indexer:instances[%d] repeats name %q", i, instance.Name)
+		}
+		names[instance.Name] = true
+
+		roleDefaults, ok := rolePlugins[instance.Role]
+		if !ok {
+			return nil, fmt.Errorf("indexer:instances %q: role must be %q, %q or %q, got %q", instance.Name, roleIndexer, roleWitness, roleApi, instance.Role)
+		}
+		if instance.Plugins == nil {
+			instance.Plugins = roleDefaults
+			// The top-level list only applies to instances indexing into Postgres
+			if instance.Role == roleIndexer {
+				instance.Plugins = defaults.Plugins
+			}
+		}
+
+		if instance.Role == roleIndexer && !instance.indexesPostgres() {
+			return nil, fmt.Errorf("indexer:instances %q: the %s role needs the postgres_indexer plugin, got %v", instance.Name, roleIndexer, instance.Plugins)
+		}
+		if instance.Database != "" && !instance.indexesPostgres() {
+			return nil, fmt.Errorf("indexer:instances %q: database needs the postgres_indexer plugin", instance.Name)
+		}
+
+		if err := instance.validate(); err != nil {
+			return nil, fmt.Errorf("indexer:instances %q: %w", instance.Name, err)
+		}
+
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+func (i *indexerInstance) indexesPostgres() bool {
+	return slices.Contains(i.Plugins, "postgres_indexer")
+}
+
+// validate checks the node settings shared by the top-level config and every instance.
+func (c *indexerConfig) validate() error {
+	if len(c.SeedNodes) == 0 {
+		return fmt.Errorf("indexer:seedNodes must list at least one seed node")
+	}
+	for _, seedNode := range c.SeedNodes {
+		host, port, err := net.SplitHostPort(seedNode)
+		if err != nil || host == "" {
+			return fmt.Errorf("indexer:seedNodes entry %q must be host:port", seedNode)
+		}
+		if portNumber, err := strconv.Atoi(port); err != nil || portNumber < 1 || portNumber > 65535 {
+			return fmt.Errorf("indexer:seedNodes entry %q has an invalid port", seedNode)
+		}
+	}
+
+	if c.Mode < indexerModeWrite || c.Mode > indexerModeAll {
+		return fmt.Errorf("indexer:mode must be %d (write), %d (query) or %d (all), got %d", indexerModeWrite, indexerModeQuery, indexerModeAll, c.Mode)
+	}
+
+	if c.StartBlock < 0 {
+		return fmt.Errorf("indexer:startBlock must not be negative, got %d", c.StartBlock)
+	}
+
+	return nil
+}
+
+// Colors of the blue/green clusters in actaboards-indexer-db-postgres.
+const (
+	colorBlue  = "blue"
+	colorGreen = "green"
+)
+
+// postgresCluster is one entry of the db stack's PostgresClusters output.
+type postgresCluster struct {
+	Color       string
+	ClusterName string
+	SecretName  string
+}
+
+// loadPostgresClusters reads which clusters the db stack runs and which one serves
+// PostgresSecretName. Stacks from before blue/green only export the latter, which is blue.
+func loadPostgresClusters(postgresStack *pulumi.StackReference) ([]postgresCluster, string, error) {
+	activeOutput, err := postgresStack.GetOutputDetails("PostgresActive")
+	if err != nil {
+		return nil, "", err
+	}
+
+	active, _ := activeOutput.Value.(string)
+	if active == "" {
+		clusterNameOutput, err := postgresStack.GetOutputDetails("PostgresClusterName")
+		if err != nil {
+			return nil, "", err
+		}
+
+		secretNameOutput, err := postgresStack.GetOutputDetails("PostgresSecretName")
+		if err != nil {
+			return nil, "", err
+		}
+
+		clusterName, _ := clusterNameOutput.Value.(string)
+		secretName, _ := secretNameOutput.Value.(string)
+
+		return []postgresCluster{{Color: colorBlue, ClusterName: clusterName, SecretName: secretName}}, colorBlue, nil
+	}
+
+	clustersOutput, err := postgresStack.GetOutputDetails("PostgresClusters")
+	if err != nil {
+		return nil, "", err
+	}
+
+	clusters, _ := clustersOutput.Value.(map[string]interface{})
+
+	result := []postgresCluster{}
+	for _, color := range []string{colorBlue, colorGreen} {
+		cluster, ok := clusters[color].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		clusterName, _ := cluster["clusterName"].(string)
+		secretName, _ := cluster["secretName"].(string)
+		result = append(result, postgresCluster{Color: color, ClusterName: clusterName, SecretName: secretName})
+	}
+
+	if len(result) == 0 {
+		return nil, "", fmt.Errorf("actaboards-indexer-db-postgres exports no PostgresClusters")
+	}
+
+	return result, active, nil
+}
+
+// indexerArgs maps the instance onto the indexer component. postgresSecretName is
+// only used when the instance runs the postgres_indexer plugin.
+func (i *indexerInstance) indexerArgs(name string, namespaceName pulumi.StringInput, genesisURL pulumi.StringInput, postgresSecretName pulumi.StringInput) *actaboards.IndexerArgs {
+	args := &actaboards.IndexerArgs{
+		Name:       pulumi.String(name),
+		Namespace:  namespaceName,
+		Image:      pulumi.String(i.Image),
+		GenesisURL: genesisURL,
+		SeedNodes:  pulumi.ToStringArray(i.SeedNodes),
+		Plugins:    pulumi.ToStringArray(i.Plugins),
+	}
+
+	if i.indexesPostgres() {
+		args.PostgresIndexerSecretName = postgresSecretName
+		args.PostgresIndexerSecretKey = pulumi.String("uri")
+		args.PostgresIndexerMode = pulumi.Int(i.Mode)
+		args.PostgresIndexerOperationString = pulumi.Bool(i.OperationString)
+		args.PostgresIndexerVisitor = pulumi.Bool(i.Visitor)
+		args.PostgresIndexerStartBlock = pulumi.Int(i.StartBlock)
+	}
+
+	return args
+}
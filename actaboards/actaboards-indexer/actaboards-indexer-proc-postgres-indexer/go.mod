@@ -6,6 +6,7 @@ toolchain go1.24.12
 
 require (
 	github.com/mirrorboards-go/mirrorboards-pulumi v0.0.0
+	github.com/pulumi/pulumi-kubernetes/sdk/v4 v4.25.0
 	github.com/pulumi/pulumi/sdk/v3 v3.214.0
 )
 
@@ -63,7 +64,6 @@ require (
 	github.com/pulumi/esc v0.17.0 // indirect
 	github.com/pulumi/pulumi-cloudflare/sdk/v6 v6.4.1 // indirect
 	github.com/pulumi/pulumi-digitalocean/sdk/v4 v4.56.0 // indirect
-	github.com/pulumi/pulumi-kubernetes/sdk/v4 v4.25.0 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
 	github.com/rogpeppe/go-internal v1.13.1 // indirect
 	github.com/santhosh-tekuri/jsonschema/v5 v5.0.0 // indirect
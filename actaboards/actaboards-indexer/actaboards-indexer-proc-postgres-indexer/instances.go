@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/url"
+
+	"github.com/mirrorboards-go/mirrorboards-pulumi/blockchain/actaboards"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// newIndexerDatabase gives an instance its own database in the indexer Postgres cluster,
+// owned by the cluster's app user, and a secret with the app secret's uri pointed at it.
+// It returns the name of that secret.
+func newIndexerDatabase(ctx *pulumi.Context, name string, instance indexerInstance, namespaceName pulumi.StringOutput, postgresClusterName pulumi.StringOutput, postgresSecretName pulumi.StringOutput) (pulumi.StringOutput, error) {
+	database, err := apiextensions.NewCustomResource(ctx, name+"-database", &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("postgresql.cnpg.io/v1"),
+		Kind:       pulumi.String("Database"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespaceName,
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"name":  pulumi.String(instance.Database),
+				"owner": pulumi.String("app"),
+				"cluster": pulumi.Map{
+					"name": postgresClusterName,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	// Look up the app secret to reuse its credentials for the instance's database
+	appSecret, err := corev1.GetSecret(ctx, name+"-postgres-secret-lookup",
+		pulumi.All(namespaceName, postgresSecretName).ApplyT(func(args []any) pulumi.ID {
+			return pulumi.ID(args[0].(string) + "/" + args[1].(string))
+		}).(pulumi.IDOutput),
+		nil,
+	)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	uri := appSecret.Data.ApplyT(func(data map[string]string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(data["uri"])
+		if err != nil {
+			return "", err
+		}
+
+		appUri, err := url.Parse(string(decoded))
+		if err != nil {
+			return "", err
+		}
+		appUri.Path = "/" + instance.Database
+
+		return appUri.String(), nil
+	}).(pulumi.StringOutput)
+
+	secret, err := corev1.NewSecret(ctx, name+"-postgres-secret", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name + "-postgres"),
+			Namespace: namespaceName,
+		},
+		StringData: pulumi.StringMap{
+			"uri":    pulumi.ToSecret(uri).(pulumi.StringOutput),
+			"dbname": pulumi.String(instance.Database),
+		},
+	}, pulumi.DependsOn([]pulumi.Resource{database}))
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	return secret.Metadata.Name().Elem(), nil
+}
+
+// newIndexerInstance deploys one indexer node and returns its entry in the Indexers output.
+func newIndexerInstance(ctx *pulumi.Context, name string, instance indexerInstance, namespaceName pulumi.StringOutput, genesisURL pulumi.StringOutput, postgresSecretName pulumi.StringOutput) (pulumi.Map, error) {
+	_, err := actaboards.NewIndexer(ctx, name, instance.indexerArgs(name, namespaceName, genesisURL, postgresSecretName))
+	if err != nil {
+		return nil, err
+	}
+
+	indexerExport := pulumi.Map{
+		"role": pulumi.String(instance.Role),
+	}
+	if instance.indexesPostgres() {
+		indexerExport["postgresSecretName"] = postgresSecretName
+	}
+
+	return indexerExport, nil
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"github.com/mirrorboards-go/mirrorboards-pulumi/blockchain/actaboards"
 	"github.com/mirrorboards-go/mirrorboards-pulumi/namespace"
 
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
@@ -32,30 +31,72 @@ func main() {
 
 		postgresSecretName := postgresStack.GetStringOutput(pulumi.String("PostgresSecretName"))
 
-		_, err = actaboards.NewIndexer(ctx, ns.Get("node", "postgres-indexer"), &actaboards.IndexerArgs{
-			Name:       pulumi.String(ns.Get("node", "postgres-indexer")),
-			Namespace:  namespaceName,
-			Image:      pulumi.String("ghcr.io/actaboards/actaboards-core:latest"),
-			GenesisURL: genesisURL,
-			SeedNodes: pulumi.StringArray{
-				pulumi.String("node01.acta.network:2771"),
-				pulumi.String("node02.acta.network:2771"),
-			},
-			Plugins: pulumi.StringArray{
-				pulumi.String("witness"),
-				pulumi.String("postgres_indexer"),
-			},
-			PostgresIndexerSecretName:      postgresSecretName,
-			PostgresIndexerSecretKey:       pulumi.String("uri"),
-			PostgresIndexerMode:            pulumi.Int(2),
-			PostgresIndexerOperationString: pulumi.Bool(true),
-			PostgresIndexerVisitor:         pulumi.Bool(true),
-			PostgresIndexerStartBlock:      pulumi.Int(0),
-		})
+		// Blue/green clusters of the db stack. While a reindex runs there are two, and every
+		// postgres_indexer instance runs once per cluster so the idle one can catch up.
+		postgresClusters, postgresActive, err := loadPostgresClusters(postgresStack)
 		if err != nil {
 			return err
 		}
 
+		defaults, err := loadIndexerConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		instances, err := loadIndexerInstances(ctx, defaults)
+		if err != nil {
+			return err
+		}
+
+		indexers := pulumi.Map{}
+
+		for _, instance := range instances {
+			// Nodes without postgres_indexer have no cluster to follow
+			if !instance.indexesPostgres() {
+				name := ns.Get("node", instance.Name)
+
+				indexerExport, err := newIndexerInstance(ctx, name, instance, namespaceName, genesisURL, postgresSecretName)
+				if err != nil {
+					return err
+				}
+
+				indexers[instance.Name] = indexerExport
+				continue
+			}
+
+			for _, cluster := range postgresClusters {
+				// Blue keeps the names the stack used before it had colors
+				key := instance.Name
+				name := ns.Get("node", instance.Name)
+				if cluster.Color != colorBlue {
+					key = instance.Name + "-" + cluster.Color
+					name = ns.Get("node", instance.Name, cluster.Color)
+				}
+
+				clusterPostgresSecretName := pulumi.String(cluster.SecretName).ToStringOutput()
+
+				// A database of the instance's own stays in its color's cluster, so switching
+				// postgres:active never moves it onto a cluster that has not indexed it
+				if instance.Database != "" {
+					clusterPostgresSecretName, err = newIndexerDatabase(ctx, name, instance, namespaceName, pulumi.String(cluster.ClusterName).ToStringOutput(), clusterPostgresSecretName)
+					if err != nil {
+						return err
+					}
+				}
+
+				indexerExport, err := newIndexerInstance(ctx, name, instance, namespaceName, genesisURL, clusterPostgresSecretName)
+				if err != nil {
+					return err
+				}
+
+				indexerExport["color"] = pulumi.String(cluster.Color)
+				indexerExport["active"] = pulumi.Bool(cluster.Color == postgresActive)
+				indexers[key] = indexerExport
+			}
+		}
+
+		ctx.Export("Indexers", indexers)
+
 		return nil
 	})
 }
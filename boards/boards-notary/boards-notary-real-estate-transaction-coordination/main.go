@@ -4,7 +4,9 @@ import (
 	"github.com/mirrorboards-go/mirrorboards-pulumi/namespace"
 	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
 	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
 	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
@@ -12,104 +14,109 @@ func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
 		ns := namespace.NewNamespace("mirrorboards", "platform")
 
-		_, err := apiextensions.NewCustomResource(ctx, ns.Get("pulumi-stacks-mirrorboard-xxx"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("pulumi.com/v1"),
-			Kind:       pulumi.String("Stack"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name:      pulumi.String("mirrorboard-xxx"),
-				Namespace: pulumi.String("pulumi-stacks"),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"serviceAccountName": pulumi.String("pulumi"),
-					"stack":              pulumi.String("mirrorboards/mirrorboard/xxx"),
-					"fluxSource": pulumi.Map{
-						"sourceRef": pulumi.Map{
-							"apiVersion": pulumi.String("source.toolkit.fluxcd.io/v1"),
-							"kind":       pulumi.String("GitRepository"),
-							"name":       pulumi.String("mirrorboards-stacks"),
-						},
-						"dir": pulumi.String("mirrorboard/mirrorboard"),
-					},
-					"envRefs": pulumi.Map{
-						"PULUMI_ACCESS_TOKEN": pulumi.Map{
-							"type": pulumi.String("Secret"),
-							"secret": pulumi.Map{
-								"name": pulumi.String("pulumi-api-secret"),
-								"key":  pulumi.String("accessToken"),
-							},
-						},
-						"PULUMI_CONFIG_PASSPHRASE": pulumi.Map{
-							"type": pulumi.String("Secret"),
-							"secret": pulumi.Map{
-								"name": pulumi.String("pulumi-config-passphrase"),
-								"key":  pulumi.String("passphrase"),
-							},
-						},
-						"GOWORK": pulumi.Map{
-							"type": pulumi.String("Literal"),
-							"literal": pulumi.Map{
-								"value": pulumi.String("off"),
-							},
-						},
-					},
-					"destroyOnFinalize": pulumi.Bool(true),
-				},
-			},
-		})
+		stacks, err := loadPulumiStacks(ctx)
 		if err != nil {
 			return err
 		}
 
-		_, err = apiextensions.NewCustomResource(ctx, ns.Get("pulumi-stacks-mirrorboard-yyy"), &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("pulumi.com/v1"),
-			Kind:       pulumi.String("Stack"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name:      pulumi.String("mirrorboard-yyy"),
-				Namespace: pulumi.String("pulumi-stacks"),
-			},
-			OtherFields: kubernetes.UntypedArgs{
-				"spec": pulumi.Map{
-					"serviceAccountName": pulumi.String("pulumi"),
-					"stack":              pulumi.String("mirrorboards/mirrorboard/yyy"),
-					"fluxSource": pulumi.Map{
-						"sourceRef": pulumi.Map{
-							"apiVersion": pulumi.String("source.toolkit.fluxcd.io/v1"),
-							"kind":       pulumi.String("GitRepository"),
-							"name":       pulumi.String("mirrorboards-stacks"),
-						},
-						"dir": pulumi.String("mirrorboard/mirrorboard"),
+		tenants, err := loadTenants(ctx, stacks)
+		if err != nil {
+			return err
+		}
+
+		Tenants := pulumi.Map{}
+
+		// One Stack per tenant. Dropping a tenant from config deletes its Stack,
+		// which destroys the tenant's resources before the CR goes away
+		for _, tenant := range tenants {
+			stackName := "mirrorboard-" + tenant.Name
+
+			stackConfig, err := tenant.stackConfig()
+			if err != nil {
+				return err
+			}
+
+			// The operator's ServiceAccount only gets the tenant role inside the tenant's
+			// namespace, so the namespace is created here, ahead of the Stack. The tenant
+			// stack declares the same namespace and server-side applies onto it
+			tenantNamespace, err := corev1.NewNamespace(ctx, ns.Get("tenant-namespace-"+stackName), &corev1.NamespaceArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Name: pulumi.String(namespace.NewNamespace("mirrorboard", tenant.Name).Get()),
+					Labels: pulumi.StringMap{
+						"mirrorboards.network/tenant": pulumi.String(tenant.Name),
 					},
-					"envRefs": pulumi.Map{
-						"PULUMI_ACCESS_TOKEN": pulumi.Map{
-							"type": pulumi.String("Secret"),
-							"secret": pulumi.Map{
-								"name": pulumi.String("pulumi-api-secret"),
-								"key":  pulumi.String("accessToken"),
-							},
-						},
-						"PULUMI_CONFIG_PASSPHRASE": pulumi.Map{
-							"type": pulumi.String("Secret"),
-							"secret": pulumi.Map{
-								"name": pulumi.String("pulumi-config-passphrase"),
-								"key":  pulumi.String("passphrase"),
-							},
-						},
-						"GOWORK": pulumi.Map{
-							"type": pulumi.String("Literal"),
-							"literal": pulumi.Map{
-								"value": pulumi.String("off"),
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			tenantRoleBinding, err := rbacv1.NewRoleBinding(ctx, ns.Get("tenant-role-binding-"+stackName), &rbacv1.RoleBindingArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:      pulumi.String("pulumi-stacks:tenant"),
+					Namespace: tenantNamespace.Metadata.Name().Elem(),
+				},
+				RoleRef: &rbacv1.RoleRefArgs{
+					ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+					Kind:     pulumi.String("ClusterRole"),
+					Name:     pulumi.String(stacks.TenantClusterRoleName),
+				},
+				Subjects: rbacv1.SubjectArray{
+					&rbacv1.SubjectArgs{
+						Kind:      pulumi.String("ServiceAccount"),
+						Name:      pulumi.String(stacks.ServiceAccountName),
+						Namespace: pulumi.String(stacks.NamespaceName),
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			Stack, err := apiextensions.NewCustomResource(ctx, ns.Get("pulumi-stacks-"+stackName), &apiextensions.CustomResourceArgs{
+				ApiVersion: pulumi.String("pulumi.com/v1"),
+				Kind:       pulumi.String("Stack"),
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:      pulumi.String(stackName),
+					Namespace: pulumi.String(stacks.NamespaceName),
+				},
+				OtherFields: kubernetes.UntypedArgs{
+					"spec": pulumi.Map{
+						"serviceAccountName": pulumi.String(stacks.ServiceAccountName),
+						"stack":              pulumi.String(tenant.Stack),
+						"fluxSource": pulumi.Map{
+							"sourceRef": pulumi.Map{
+								"apiVersion": pulumi.String("source.toolkit.fluxcd.io/v1"),
+								"kind":       pulumi.String("GitRepository"),
+								"name":       pulumi.String(stacks.GitRepositoryName),
 							},
+							"dir": pulumi.String(tenant.Dir),
 						},
+						"config":            stackConfig,
+						"envRefs":           tenant.envRefs(),
+						"destroyOnFinalize": pulumi.Bool(true),
+
+						"resyncFrequencySeconds":      pulumi.Int(tenant.Sync.ResyncFrequencySeconds),
+						"refresh":                     pulumi.Bool(*tenant.Sync.Refresh),
+						"continueResyncOnCommitMatch": pulumi.Bool(*tenant.Sync.ContinueResyncOnCommitMatch),
+						"retryOnUpdateConflict":       pulumi.Bool(*tenant.Sync.RetryOnUpdateConflict),
+						"workspaceTemplate":           tenant.workspaceTemplate(),
 					},
-					"destroyOnFinalize": pulumi.Bool(true),
 				},
-			},
-		})
-		if err != nil {
-			return err
+			}, pulumi.DependsOn([]pulumi.Resource{tenantRoleBinding}))
+			if err != nil {
+				return err
+			}
+
+			Tenants[tenant.Name] = pulumi.Map{
+				"stackName": Stack.Metadata.Name(),
+				"stack":     pulumi.String(tenant.Stack),
+				"namespace": tenantNamespace.Metadata.Name(),
+			}
 		}
 
+		ctx.Export("Tenants", Tenants)
+
 		return nil
 	})
 }
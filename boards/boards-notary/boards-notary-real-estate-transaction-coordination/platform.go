@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// pulumiStacks is what mirrorboards-platform-pulumi-stacks provisions for the
+// operator: the namespace Stacks live in, the source they run from, the
+// ServiceAccount running them and the credentials they get.
+type pulumiStacks struct {
+	NamespaceName         string
+	GitRepositoryName     string
+	ServiceAccountName    string
+	TenantClusterRoleName string
+	AccessTokenSecretName string
+	AccessTokenSecretKey  string
+	PassphraseSecretName  string
+	PassphraseSecretKey   string
+}
+
+// loadPulumiStacks reads the platform outputs as plain values, since they are part of
+// every tenant's envRefs.
+func loadPulumiStacks(ctx *pulumi.Context) (*pulumiStacks, error) {
+	// Get operator resources from mirrorboards-platform-pulumi-stacks stack
+	platformStack, err := pulumi.NewStackReference(ctx, "mirrorboards/mirrorboards-platform-pulumi-stacks/dev", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	output := func(name string) (string, error) {
+		details, err := platformStack.GetOutputDetails(name)
+		if err != nil {
+			return "", err
+		}
+
+		value, _ := details.Value.(string)
+		if value == "" {
+			return "", fmt.Errorf("mirrorboards-platform-pulumi-stacks does not export %s", name)
+		}
+
+		return value, nil
+	}
+
+	result := &pulumiStacks{}
+	for name, field := range map[string]*string{
+		"NamespaceName":         &result.NamespaceName,
+		"GitRepositoryName":     &result.GitRepositoryName,
+		"ServiceAccountName":    &result.ServiceAccountName,
+		"TenantClusterRoleName": &result.TenantClusterRoleName,
+		"AccessTokenSecretName": &result.AccessTokenSecretName,
+		"AccessTokenSecretKey":  &result.AccessTokenSecretKey,
+		"PassphraseSecretName":  &result.PassphraseSecretName,
+		"PassphraseSecretKey":   &result.PassphraseSecretKey,
+	} {
+		if *field, err = output(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// envRefs are the credentials every mirrorboard stack runs with.
+func (stacks *pulumiStacks) envRefs() map[string]envRefConfig {
+	return map[string]envRefConfig{
+		"PULUMI_ACCESS_TOKEN":      {Secret: stacks.AccessTokenSecretName, Key: stacks.AccessTokenSecretKey},
+		"PULUMI_CONFIG_PASSPHRASE": {Secret: stacks.PassphraseSecretName, Key: stacks.PassphraseSecretKey},
+		"GOWORK":                   {Value: "off"},
+	}
+}
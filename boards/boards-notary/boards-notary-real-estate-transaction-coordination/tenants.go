@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// tenantConfig is one entry of notary:tenants, deployed as the mirrorboard-<name> Stack.
+type tenantConfig struct {
+	Name string `json:"name"`
+	// Pulumi stack to run, defaults to mirrorboards/mirrorboard/<name>.
+	Stack string `json:"stack"`
+	// Program directory in the mirrorboards-stacks repository.
+	Dir string `json:"dir"`
+	// Added to, or replacing, the pulumiStacks envRefs.
+	EnvRefs map[string]envRefConfig `json:"envRefs"`
+	// Public domain of the tenant's board.
+	Domain string `json:"domain"`
+	// Plan tier, validated by the mirrorboard program.
+	Plan string `json:"plan"`
+	// Image tags by component.
+	Images map[string]string `json:"images"`
+	// Any further stack config. Keys without a namespace go under mirrorboard:.
+	Config map[string]string `json:"config"`
+	// How the operator runs the stack, on top of notary:stackDefaults.
+	Sync syncConfig `json:"sync"`
+}
+
+// syncConfig is how the Pulumi Operator keeps a tenant stack converged. Unset fields
+// fall back to notary:stackDefaults and then to defaultSync.
+type syncConfig struct {
+	// How often the stack is re-run without a new commit, at least 60.
+	ResyncFrequencySeconds int `json:"resyncFrequencySeconds"`
+	// Refresh state from the cluster before each update, so drift is corrected.
+	Refresh *bool `json:"refresh"`
+	// Keep resyncing even when the source commit has not changed.
+	ContinueResyncOnCommitMatch *bool `json:"continueResyncOnCommitMatch"`
+	// Retry updates that failed because another update held the stack.
+	RetryOnUpdateConflict *bool `json:"retryOnUpdateConflict"`
+	// Resources of the workspace pod running the program.
+	WorkspaceResources *workspaceResources `json:"workspaceResources"`
+}
+
+type workspaceResources struct {
+	Requests map[string]string `json:"requests"`
+	Limits   map[string]string `json:"limits"`
+}
+
+// defaultSync re-runs tenant stacks every ten minutes with a refresh.
+var defaultSync = syncConfig{
+	ResyncFrequencySeconds:      600,
+	Refresh:                     pulumi.BoolRef(true),
+	ContinueResyncOnCommitMatch: pulumi.BoolRef(true),
+	RetryOnUpdateConflict:       pulumi.BoolRef(true),
+	WorkspaceResources: &workspaceResources{
+		Requests: map[string]string{"cpu": "500m", "memory": "1Gi"},
+		Limits:   map[string]string{"cpu": "1", "memory": "2Gi"},
+	},
+}
+
+// withDefaults fills the fields left unset from defaults.
+func (sync syncConfig) withDefaults(defaults syncConfig) syncConfig {
+	if sync.ResyncFrequencySeconds == 0 {
+		sync.ResyncFrequencySeconds = defaults.ResyncFrequencySeconds
+	}
+	if sync.Refresh == nil {
+		sync.Refresh = defaults.Refresh
+	}
+	if sync.ContinueResyncOnCommitMatch == nil {
+		sync.ContinueResyncOnCommitMatch = defaults.ContinueResyncOnCommitMatch
+	}
+	if sync.RetryOnUpdateConflict == nil {
+		sync.RetryOnUpdateConflict = defaults.RetryOnUpdateConflict
+	}
+	if sync.WorkspaceResources == nil {
+		sync.WorkspaceResources = defaults.WorkspaceResources
+	}
+
+	return sync
+}
+
+// envRefConfig sets a stack environment variable from a key of a Secret in
+// pulumi-stacks, or to a literal Value.
+type envRefConfig struct {
+	Secret string `json:"secret"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+}
+
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+func loadTenants(ctx *pulumi.Context, stacks *pulumiStacks) ([]tenantConfig, error) {
+	notaryCfg := config.New(ctx, "notary")
+
+	var tenants []tenantConfig
+	if err := notaryCfg.GetObject("tenants", &tenants); err != nil {
+		return nil, fmt.Errorf("notary:tenants: %w", err)
+	}
+
+	var stackDefaults syncConfig
+	if err := notaryCfg.GetObject("stackDefaults", &stackDefaults); err != nil {
+		return nil, fmt.Errorf("notary:stackDefaults: %w", err)
+	}
+	stackDefaults = stackDefaults.withDefaults(defaultSync)
+
+	seen := map[string]bool{}
+	for i := range tenants {
+		tenant := &tenants[i]
+
+		if !tenantNamePattern.MatchString(tenant.Name) {
+			return nil, fmt.Errorf("notary:tenants[%d].name must be a lowercase DNS label, got %q", i, tenant.Name)
+		}
+		if seen[tenant.Name] {
+			return nil, fmt.Errorf("notary:tenants name %q is listed twice", tenant.Name)
+		}
+		seen[tenant.Name] = true
+
+		if tenant.Stack == "" {
+			tenant.Stack = "mirrorboards/mirrorboard/" + tenant.Name
+		}
+		if tenant.Dir == "" {
+			tenant.Dir = "mirrorboard/mirrorboard"
+		}
+
+		tenant.Sync = tenant.Sync.withDefaults(stackDefaults)
+		if tenant.Sync.ResyncFrequencySeconds < 60 {
+			return nil, fmt.Errorf("notary:tenants %q sync.resyncFrequencySeconds must be at least 60, got %d", tenant.Name, tenant.Sync.ResyncFrequencySeconds)
+		}
+
+		envRefs := stacks.envRefs()
+		for name, ref := range tenant.EnvRefs {
+			if (ref.Secret == "") == (ref.Value == "") {
+				return nil, fmt.Errorf("notary:tenants %q envRefs.%s must set exactly one of secret or value", tenant.Name, name)
+			}
+			if ref.Secret != "" && ref.Key == "" {
+				return nil, fmt.Errorf("notary:tenants %q envRefs.%s needs the key of secret %q", tenant.Name, name, ref.Secret)
+			}
+			envRefs[name] = ref
+		}
+		tenant.EnvRefs = envRefs
+	}
+
+	return tenants, nil
+}
+
+// stackConfig is the tenant's spec.config, read by mirrorboard's loadMirrorboardConfig.
+func (tenant tenantConfig) stackConfig() (pulumi.StringMap, error) {
+	stackConfig := pulumi.StringMap{}
+	for key, value := range tenant.Config {
+		if !strings.Contains(key, ":") {
+			key = "mirrorboard:" + key
+		}
+		stackConfig[key] = pulumi.String(value)
+	}
+
+	stackConfig["mirrorboard:name"] = pulumi.String(tenant.Name)
+	if tenant.Domain != "" {
+		stackConfig["mirrorboard:domain"] = pulumi.String(tenant.Domain)
+	}
+	if tenant.Plan != "" {
+		stackConfig["mirrorboard:plan"] = pulumi.String(tenant.Plan)
+	}
+	if len(tenant.Images) > 0 {
+		// Stack config values are strings; mirrorboard reads this one back as an object
+		images, err := json.Marshal(tenant.Images)
+		if err != nil {
+			return nil, err
+		}
+		stackConfig["mirrorboard:images"] = pulumi.String(string(images))
+	}
+
+	return stackConfig, nil
+}
+
+// workspaceTemplate sizes the pulumi container of the tenant's workspace pod.
+func (tenant tenantConfig) workspaceTemplate() pulumi.Map {
+	resources := tenant.Sync.WorkspaceResources
+
+	return pulumi.Map{
+		"spec": pulumi.Map{
+			"podTemplate": pulumi.Map{
+				"spec": pulumi.Map{
+					"containers": pulumi.Array{
+						pulumi.Map{
+							"name": pulumi.String("pulumi"),
+							"resources": pulumi.Map{
+								"requests": pulumi.ToStringMap(resources.Requests),
+								"limits":   pulumi.ToStringMap(resources.Limits),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// envRefs renders the tenant's environment in the Stack CR's spec.envRefs format.
+func (tenant tenantConfig) envRefs() pulumi.Map {
+	envRefs := pulumi.Map{}
+	for name, ref := range tenant.EnvRefs {
+		if ref.Secret != "" {
+			envRefs[name] = pulumi.Map{
+				"type": pulumi.String("Secret"),
+				"secret": pulumi.Map{
+					"name": pulumi.String(ref.Secret),
+					"key":  pulumi.String(ref.Key),
+				},
+			}
+			continue
+		}
+
+		envRefs[name] = pulumi.Map{
+			"type": pulumi.String("Literal"),
+			"literal": pulumi.Map{
+				"value": pulumi.String(ref.Value),
+			},
+		}
+	}
+
+	return envRefs
+}
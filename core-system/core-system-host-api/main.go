@@ -25,6 +25,14 @@ func main() {
 		}
 		PostgresSecretName := postgresStack.GetStringOutput(pulumi.String("PostgresSecretName"))
 
+		// Get Gateway name from mirrorboards-platform-gateway stack
+		gatewayStack, err := pulumi.NewStackReference(ctx, "mirrorboards/mirrorboards-platform-gateway/dev", nil)
+		if err != nil {
+			return err
+		}
+		GatewayName := gatewayStack.GetStringOutput(pulumi.String("GatewayName"))
+		GatewayNamespace := gatewayStack.GetStringOutput(pulumi.String("GatewayNamespace"))
+
 		appLabels := pulumi.StringMap{
 			"app": pulumi.String("systemboards-api"),
 		}
@@ -128,8 +136,8 @@ func main() {
 				"spec": pulumi.Map{
 					"parentRefs": pulumi.Array{
 						pulumi.Map{
-							"name":        pulumi.String("mirrorboards-platform-gateway"),
-							"namespace":   pulumi.String("aks-istio-ingress"),
+							"name":        GatewayName,
+							"namespace":   GatewayNamespace,
 							"kind":        pulumi.String("Gateway"),
 							"sectionName": pulumi.String("https"),
 						},
@@ -162,6 +170,47 @@ func main() {
 			return err
 		}
 
+		// HTTPRoute for HTTP to HTTPS redirect
+		_, err = apiextensions.NewCustomResource(ctx, "core-system-host-api-httproute-redirect", &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("gateway.networking.k8s.io/v1"),
+			Kind:       pulumi.String("HTTPRoute"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("systemboards-api-httproute-redirect"),
+				Namespace: NamespaceName,
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"parentRefs": pulumi.Array{
+						pulumi.Map{
+							"name":        GatewayName,
+							"namespace":   GatewayNamespace,
+							"kind":        pulumi.String("Gateway"),
+							"sectionName": pulumi.String("http"),
+						},
+					},
+					"hostnames": pulumi.Array{
+						pulumi.String("system.mirrorboards.network"),
+					},
+					"rules": pulumi.Array{
+						pulumi.Map{
+							"filters": pulumi.Array{
+								pulumi.Map{
+									"type": pulumi.String("RequestRedirect"),
+									"requestRedirect": pulumi.Map{
+										"scheme":     pulumi.String("https"),
+										"statusCode": pulumi.Int(301),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+
 		ctx.Export("DeploymentName", Deployment.Metadata.Name())
 		ctx.Export("ServiceName", Service.Metadata.Name())
 		ctx.Export("Hostname", pulumi.String("https://system.mirrorboards.network"))
@@ -1,6 +0,0 @@
-#!/bin/bash
-
-NAMESPACE="core-xauth"
-
-echo "Redis URL:"
-echo "redis://dragonfly.$NAMESPACE.svc.cluster.local:6379"
@@ -5,7 +5,7 @@ go 1.24.7
 toolchain go1.24.12
 
 require (
-	github.com/pulumi/pulumi-kubernetes/sdk/v4 v4.25.0
+	github.com/mirrorboards-go/mirrorboards-pulumi v0.0.0
 	github.com/pulumi/pulumi/sdk/v3 v3.214.0
 )
 
@@ -61,6 +61,7 @@ require (
 	github.com/pkg/term v1.1.0 // indirect
 	github.com/pulumi/appdash v0.0.0-20231130102222-75f619a67231 // indirect
 	github.com/pulumi/esc v0.17.0 // indirect
+	github.com/pulumi/pulumi-kubernetes/sdk/v4 v4.25.0 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
 	github.com/rogpeppe/go-internal v1.13.1 // indirect
 	github.com/santhosh-tekuri/jsonschema/v5 v5.0.0 // indirect
@@ -91,3 +92,5 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	lukechampine.com/frand v1.4.2 // indirect
 )
+
+replace github.com/mirrorboards-go/mirrorboards-pulumi => ../../../mirrorboards-go/mirrorboards-pulumi
@@ -1,11 +1,14 @@
 package main
 
 import (
-	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
-	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	"github.com/mirrorboards-go/mirrorboards-pulumi/charts"
+
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
+// dragonflyPort is the port the operator exposes Dragonfly's Redis protocol on.
+const dragonflyPort = 6379
+
 func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
 		apiStack, err := pulumi.NewStackReference(ctx, "mirrorboards/core-xauth/dev", nil)
@@ -15,41 +18,33 @@ func main() {
 
 		NamespaceName := apiStack.GetStringOutput(pulumi.String("NamespaceName"))
 
-		_, err = apiextensions.NewCustomResource(ctx, "dragonfly", &apiextensions.CustomResourceArgs{
-			ApiVersion: pulumi.String("dragonflydb.io/v1alpha1"),
-			Kind:       pulumi.String("Dragonfly"),
-			Metadata: &metav1.ObjectMetaArgs{
-				Name:      pulumi.String("dragonfly"),
-				Namespace: NamespaceName,
-				Labels: pulumi.StringMap{
-					"app.kubernetes.io/name":       pulumi.String("dragonfly"),
-					"app.kubernetes.io/instance":   pulumi.String("dragonfly-sample"),
-					"app.kubernetes.io/part-of":    pulumi.String("dragonfly-operator"),
-					"app.kubernetes.io/managed-by": pulumi.String("kustomize"),
-					"app.kubernetes.io/created-by": pulumi.String("dragonfly-operator"),
-				},
-			},
-			OtherFields: map[string]interface{}{
-				"spec": map[string]interface{}{
-					"replicas": 1,
-					"resources": map[string]interface{}{
-						"requests": map[string]interface{}{
-							"cpu":    "500m",
-							"memory": "500Mi",
-						},
-						"limits": map[string]interface{}{
-							"cpu":    "600m",
-							"memory": "750Mi",
-						},
-					},
-				},
-			},
-		})
+		// The instance was declared as a plain CustomResource named "dragonfly" before it
+		// moved onto the shared component; the alias keeps the existing object instead of
+		// replacing it
+		_, err = charts.NewDragonflyInstance(ctx, "core-xauth-dragonfly", &charts.NewDragonflyInstanceArgs{
+			Name:      pulumi.String("dragonfly"),
+			Namespace: NamespaceName,
+		}, pulumi.Aliases([]pulumi.Alias{
+			{Name: pulumi.String("dragonfly")},
+		}))
 		if err != nil {
 			return err
 		}
 
-		ctx.Export("DragonflyServiceName", pulumi.Sprintf("dragonfly.%s.svc.cluster.local", NamespaceName))
+		RedisHost := pulumi.Sprintf("dragonfly.%s.svc.cluster.local", NamespaceName)
+		RedisUrl := pulumi.Sprintf("redis://%s:%d", RedisHost, dragonflyPort)
+
+		ctx.Export("RedisHost", RedisHost)
+		ctx.Export("RedisPort", pulumi.Int(dragonflyPort))
+		ctx.Export("RedisUrl", RedisUrl)
+		ctx.Export("Redis", pulumi.Map{
+			"host": RedisHost,
+			"port": pulumi.Int(dragonflyPort),
+			"url":  RedisUrl,
+		})
+
+		// Bare hostname, kept for consumers that have not moved to the outputs above
+		ctx.Export("DragonflyServiceName", RedisHost)
 
 		return nil
 	})
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// protectedHostConfig is one entry of xauth:protectedHosts. The Gateway serving Hostname
+// rejects requests to PathPrefixes without a token issued by xauth, except for PublicPaths.
+type protectedHostConfig struct {
+	Hostname string `json:"hostname"`
+	// Defaults to the whole host.
+	PathPrefixes []string `json:"pathPrefixes"`
+	// Exempt from the token requirement even inside PathPrefixes.
+	PublicPaths []string `json:"publicPaths"`
+	// Accepted aud claims; any audience when empty.
+	Audiences []string `json:"audiences"`
+	// Project of the stack exporting GatewayName and GatewayNamespace of the Gateway the
+	// host is served on, e.g. actaboards-platform-gateway. Defaults to the platform gateway.
+	GatewayStack string `json:"gatewayStack"`
+}
+
+func loadProtectedHosts(ctx *pulumi.Context) ([]protectedHostConfig, error) {
+	xauthCfg := config.New(ctx, "xauth")
+
+	var hosts []protectedHostConfig
+	if err := xauthCfg.GetObject("protectedHosts", &hosts); err != nil {
+		return nil, fmt.Errorf("xauth:protectedHosts must be a list of hosts: %w", err)
+	}
+
+	hostnames := map[string]bool{}
+	for i := range hosts {
+		host := &hosts[i]
+
+		if host.Hostname == "" {
+			return nil, fmt.Errorf("xauth:protectedHosts[%d] needs a hostname", i)
+		}
+		if hostnames[host.Hostname] {
+			return nil, fmt.Errorf("xauth:protectedHosts repeats hostname %q", host.Hostname)
+		}
+		hostnames[host.Hostname] = true
+
+		if len(host.PathPrefixes) == 0 {
+			host.PathPrefixes = []string{"/"}
+		}
+		if host.PublicPaths == nil {
+			host.PublicPaths = []string{"/health", "/healthz", "/ready"}
+		}
+		for _, path := range append(host.PathPrefixes, host.PublicPaths...) {
+			if !strings.HasPrefix(path, "/") {
+				return nil, fmt.Errorf("xauth:protectedHosts %q: paths must start with /, got %q", host.Hostname, path)
+			}
+		}
+	}
+
+	return hosts, nil
+}
+
+// prefixPaths turns path prefixes into Istio path matches covering the prefix itself
+// and everything below it.
+func prefixPaths(prefixes []string) pulumi.Array {
+	paths := pulumi.Array{}
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix != "" {
+			paths = append(paths, pulumi.String(prefix))
+		}
+		paths = append(paths, pulumi.String(prefix+"/*"))
+	}
+
+	return paths
+}
+
+// gatewayRef is a Gateway the policies attach to.
+type gatewayRef struct {
+	Name      pulumi.StringOutput
+	Namespace pulumi.StringOutput
+}
+
+func (g gatewayRef) targetRefs() pulumi.Array {
+	return pulumi.Array{
+		pulumi.Map{
+			"group": pulumi.String("gateway.networking.k8s.io"),
+			"kind":  pulumi.String("Gateway"),
+			"name":  g.Name,
+		},
+	}
+}
+
+// newJwtPolicies enforces xauth tokens on the Gateways serving the protected hosts.
+// Every Gateway gets one RequestAuthentication validating xauth tokens, and every host
+// a DENY AuthorizationPolicy for requests without a valid one on its protected paths.
+// Both attach to the Gateway through targetRefs, so the gateway's proxy enforces them
+// before a request reaches any workload.
+func newJwtPolicies(ctx *pulumi.Context, hosts []protectedHostConfig, issuerUrl string, jwksUrl string, platformGateway gatewayRef) error {
+	gateways := map[string]gatewayRef{"": platformGateway}
+	requestAuthentications := map[string]pulumi.Resource{}
+
+	for _, host := range hosts {
+		gatewayKey := host.GatewayStack
+
+		gateway, ok := gateways[gatewayKey]
+		if !ok {
+			gatewayStack, err := pulumi.NewStackReference(ctx, "core-xauth-host-api-gateway-"+gatewayKey, &pulumi.StackReferenceArgs{
+				Name: pulumi.String("mirrorboards/" + gatewayKey + "/dev"),
+			})
+			if err != nil {
+				return err
+			}
+
+			gateway = gatewayRef{
+				Name:      gatewayStack.GetStringOutput(pulumi.String("GatewayName")),
+				Namespace: gatewayStack.GetStringOutput(pulumi.String("GatewayNamespace")),
+			}
+			gateways[gatewayKey] = gateway
+		}
+
+		// Rejects requests carrying an invalid token; requests without one pass through
+		// to the AuthorizationPolicies below
+		requestAuthentication, ok := requestAuthentications[gatewayKey]
+		if !ok {
+			resourceName := "core-xauth-host-api-request-authentication"
+			if gatewayKey != "" {
+				resourceName += "-" + gatewayKey
+			}
+
+			var err error
+			requestAuthentication, err = apiextensions.NewCustomResource(ctx, resourceName, &apiextensions.CustomResourceArgs{
+				ApiVersion: pulumi.String("security.istio.io/v1"),
+				Kind:       pulumi.String("RequestAuthentication"),
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:      pulumi.Sprintf("%s-xauth-jwt", gateway.Name),
+					Namespace: gateway.Namespace,
+				},
+				OtherFields: kubernetes.UntypedArgs{
+					"spec": pulumi.Map{
+						"targetRefs": gateway.targetRefs(),
+						"jwtRules": pulumi.Array{
+							pulumi.Map{
+								"issuer":               pulumi.String(issuerUrl),
+								"jwksUri":              pulumi.String(jwksUrl),
+								"forwardOriginalToken": pulumi.Bool(true),
+							},
+						},
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			requestAuthentications[gatewayKey] = requestAuthentication
+		}
+
+		// The Host header may carry the listener port
+		to := pulumi.Array{
+			pulumi.Map{
+				"operation": pulumi.Map{
+					"hosts":    pulumi.StringArray{pulumi.String(host.Hostname), pulumi.String(host.Hostname + ":*")},
+					"paths":    prefixPaths(host.PathPrefixes),
+					"notPaths": prefixPaths(host.PublicPaths),
+				},
+			},
+		}
+
+		rules := pulumi.Array{
+			pulumi.Map{
+				"from": pulumi.Array{
+					pulumi.Map{
+						"source": pulumi.Map{
+							"notRequestPrincipals": pulumi.StringArray{pulumi.String("*")},
+						},
+					},
+				},
+				"to": to,
+			},
+		}
+		if len(host.Audiences) > 0 {
+			rules = append(rules, pulumi.Map{
+				"from": pulumi.Array{
+					pulumi.Map{
+						"source": pulumi.Map{
+							"requestPrincipals": pulumi.StringArray{pulumi.String("*")},
+						},
+					},
+				},
+				"to": to,
+				"when": pulumi.Array{
+					pulumi.Map{
+						"key":       pulumi.String("request.auth.audiences"),
+						"notValues": pulumi.ToStringArray(host.Audiences),
+					},
+				},
+			})
+		}
+
+		policyName := strings.ReplaceAll(host.Hostname, ".", "-")
+
+		_, err := apiextensions.NewCustomResource(ctx, "core-xauth-host-api-authorization-policy-"+policyName, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("security.istio.io/v1"),
+			Kind:       pulumi.String("AuthorizationPolicy"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(policyName + "-require-xauth-jwt"),
+				Namespace: gateway.Namespace,
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"targetRefs": gateway.targetRefs(),
+					"action":     pulumi.String("DENY"),
+					"rules":      rules,
+				},
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{requestAuthentication}))
+		if err != nil {
+			return err
+		}
+	}
+
+	protectedHostnames := []string{}
+	for _, host := range hosts {
+		protectedHostnames = append(protectedHostnames, host.Hostname)
+	}
+	slices.Sort(protectedHostnames)
+
+	ctx.Export("ProtectedHosts", pulumi.ToStringArray(protectedHostnames))
+
+	return nil
+}
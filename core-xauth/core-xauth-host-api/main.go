@@ -0,0 +1,273 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+func main() {
+	pulumi.Run(func(ctx *pulumi.Context) error {
+		xauthCfg := config.New(ctx, "xauth")
+
+		Hostname := xauthCfg.Require("hostname")
+
+		Image := xauthCfg.Get("image")
+		if Image == "" {
+			Image = "ghcr.io/mirrorboards/core-xauth:main"
+		}
+
+		// Path the service publishes its signing keys on, relative to the issuer
+		JwksPath := xauthCfg.Get("jwksPath")
+		if JwksPath == "" {
+			JwksPath = "/.well-known/jwks.json"
+		}
+		JwksPath = "/" + strings.TrimPrefix(JwksPath, "/")
+
+		IssuerUrl := "https://" + Hostname
+		JwksUrl := IssuerUrl + JwksPath
+
+		protectedHosts, err := loadProtectedHosts(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Get namespace from core-xauth stack
+		coreXauthStack, err := pulumi.NewStackReference(ctx, "mirrorboards/core-xauth/dev", nil)
+		if err != nil {
+			return err
+		}
+		NamespaceName := coreXauthStack.GetStringOutput(pulumi.String("NamespaceName"))
+
+		// Get Postgres secret name from core-xauth-db-postgres stack
+		postgresStack, err := pulumi.NewStackReference(ctx, "mirrorboards/core-xauth-db-postgres/dev", nil)
+		if err != nil {
+			return err
+		}
+		PostgresSecretName := postgresStack.GetStringOutput(pulumi.String("PostgresSecretName"))
+
+		// Get Redis URL from core-xauth-db-redis stack
+		redisStack, err := pulumi.NewStackReference(ctx, "mirrorboards/core-xauth-db-redis/dev", nil)
+		if err != nil {
+			return err
+		}
+		RedisUrl := redisStack.GetStringOutput(pulumi.String("RedisUrl"))
+
+		// Get Gateway name from mirrorboards-platform-gateway stack
+		gatewayStack, err := pulumi.NewStackReference(ctx, "mirrorboards/mirrorboards-platform-gateway/dev", nil)
+		if err != nil {
+			return err
+		}
+		GatewayName := gatewayStack.GetStringOutput(pulumi.String("GatewayName"))
+		GatewayNamespace := gatewayStack.GetStringOutput(pulumi.String("GatewayNamespace"))
+
+		appLabels := pulumi.StringMap{
+			"app": pulumi.String("core-xauth-api"),
+		}
+
+		Deployment, err := appsv1.NewDeployment(ctx, "core-xauth-host-api-deployment", &appsv1.DeploymentArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("core-xauth-api"),
+				Namespace: NamespaceName,
+				Labels:    appLabels,
+			},
+			Spec: &appsv1.DeploymentSpecArgs{
+				Replicas: pulumi.Int(1),
+				Selector: &metav1.LabelSelectorArgs{
+					MatchLabels: appLabels,
+				},
+				Template: &corev1.PodTemplateSpecArgs{
+					Metadata: &metav1.ObjectMetaArgs{
+						Labels: appLabels,
+					},
+					Spec: &corev1.PodSpecArgs{
+						Containers: corev1.ContainerArray{
+							&corev1.ContainerArgs{
+								Name:            pulumi.String("core-xauth-api"),
+								Image:           pulumi.String(Image),
+								ImagePullPolicy: pulumi.String("Always"),
+								Ports: corev1.ContainerPortArray{
+									&corev1.ContainerPortArgs{
+										ContainerPort: pulumi.Int(3004),
+										Name:          pulumi.String("http"),
+									},
+								},
+								Env: corev1.EnvVarArray{
+									&corev1.EnvVarArgs{
+										Name:  pulumi.String("PORT"),
+										Value: pulumi.String("3004"),
+									},
+									&corev1.EnvVarArgs{
+										Name:  pulumi.String("XAUTH_ISSUER_URL"),
+										Value: pulumi.String(IssuerUrl),
+									},
+									&corev1.EnvVarArgs{
+										Name: pulumi.String("XAUTH_POSTGRES_URI"),
+										ValueFrom: &corev1.EnvVarSourceArgs{
+											SecretKeyRef: &corev1.SecretKeySelectorArgs{
+												Name: PostgresSecretName,
+												Key:  pulumi.String("uri"),
+											},
+										},
+									},
+									&corev1.EnvVarArgs{
+										Name:  pulumi.String("XAUTH_REDIS_URL"),
+										Value: RedisUrl,
+									},
+								},
+								Resources: &corev1.ResourceRequirementsArgs{
+									Requests: pulumi.StringMap{
+										"memory": pulumi.String("128Mi"),
+										"cpu":    pulumi.String("100m"),
+									},
+									Limits: pulumi.StringMap{
+										"memory": pulumi.String("512Mi"),
+										"cpu":    pulumi.String("500m"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		Service, err := corev1.NewService(ctx, "core-xauth-host-api-service", &corev1.ServiceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("core-xauth-api"),
+				Namespace: NamespaceName,
+				Labels:    appLabels,
+			},
+			Spec: &corev1.ServiceSpecArgs{
+				Selector: appLabels,
+				Ports: corev1.ServicePortArray{
+					&corev1.ServicePortArgs{
+						Name:       pulumi.String("http"),
+						Port:       pulumi.Int(3004),
+						TargetPort: pulumi.Int(3004),
+						Protocol:   pulumi.String("TCP"),
+					},
+				},
+				Type: pulumi.String("ClusterIP"),
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{Deployment}))
+		if err != nil {
+			return err
+		}
+
+		_, err = apiextensions.NewCustomResource(ctx, "core-xauth-host-api-httproute", &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("gateway.networking.k8s.io/v1"),
+			Kind:       pulumi.String("HTTPRoute"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("core-xauth-api-httproute"),
+				Namespace: NamespaceName,
+				Annotations: pulumi.StringMap{
+					"external-dns.alpha.kubernetes.io/hostname": pulumi.String(Hostname),
+				},
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"parentRefs": pulumi.Array{
+						pulumi.Map{
+							"name":        GatewayName,
+							"namespace":   GatewayNamespace,
+							"kind":        pulumi.String("Gateway"),
+							"sectionName": pulumi.String("https"),
+						},
+					},
+					"hostnames": pulumi.Array{
+						pulumi.String(Hostname),
+					},
+					"rules": pulumi.Array{
+						pulumi.Map{
+							"matches": pulumi.Array{
+								pulumi.Map{
+									"path": pulumi.Map{
+										"type":  pulumi.String("PathPrefix"),
+										"value": pulumi.String("/"),
+									},
+								},
+							},
+							"backendRefs": pulumi.Array{
+								pulumi.Map{
+									"name": Service.Metadata.Name(),
+									"port": pulumi.Int(3004),
+								},
+							},
+						},
+					},
+				},
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{Service}))
+		if err != nil {
+			return err
+		}
+
+		// HTTPRoute for HTTP to HTTPS redirect
+		_, err = apiextensions.NewCustomResource(ctx, "core-xauth-host-api-httproute-redirect", &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("gateway.networking.k8s.io/v1"),
+			Kind:       pulumi.String("HTTPRoute"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("core-xauth-api-httproute-redirect"),
+				Namespace: NamespaceName,
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"parentRefs": pulumi.Array{
+						pulumi.Map{
+							"name":        GatewayName,
+							"namespace":   GatewayNamespace,
+							"kind":        pulumi.String("Gateway"),
+							"sectionName": pulumi.String("http"),
+						},
+					},
+					"hostnames": pulumi.Array{
+						pulumi.String(Hostname),
+					},
+					"rules": pulumi.Array{
+						pulumi.Map{
+							"filters": pulumi.Array{
+								pulumi.Map{
+									"type": pulumi.String("RequestRedirect"),
+									"requestRedirect": pulumi.Map{
+										"scheme":     pulumi.String("https"),
+										"statusCode": pulumi.Int(301),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = newJwtPolicies(ctx, protectedHosts, IssuerUrl, JwksUrl, gatewayRef{
+			Name:      GatewayName,
+			Namespace: GatewayNamespace,
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("DeploymentName", Deployment.Metadata.Name())
+		ctx.Export("ServiceName", Service.Metadata.Name())
+		ctx.Export("Hostname", pulumi.String(IssuerUrl))
+		ctx.Export("IssuerUrl", pulumi.String(IssuerUrl))
+		ctx.Export("JwksUrl", pulumi.String(JwksUrl))
+		ctx.Export("OidcDiscoveryUrl", pulumi.String(IssuerUrl+"/.well-known/openid-configuration"))
+
+		return nil
+	})
+}
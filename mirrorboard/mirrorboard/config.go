@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Plan tiers a tenant can be on.
+const (
+	planFree       = "free"
+	planPro        = "pro"
+	planEnterprise = "enterprise"
+)
+
+// mirrorboardConfig is the tenant's mirrorboard: config. The Pulumi Operator sets it
+// from the tenant's entry in boards-notary's notary:tenants.
+type mirrorboardConfig struct {
+	// Tenant name, defaults to the stack name.
+	Name string
+	// Public hostname of the tenant's board, defaults to <name>.mirrorboards.network.
+	Domain string
+	Plan   string
+	// Image tags by component, e.g. streamwaves-connect: v1.4.0.
+	Images map[string]string
+}
+
+func loadMirrorboardConfig(ctx *pulumi.Context) (*mirrorboardConfig, error) {
+	mirrorboardCfg := config.New(ctx, "mirrorboard")
+
+	cfg := &mirrorboardConfig{
+		Name:   mirrorboardCfg.Get("name"),
+		Domain: mirrorboardCfg.Get("domain"),
+		Plan:   mirrorboardCfg.Get("plan"),
+	}
+
+	if err := mirrorboardCfg.GetObject("images", &cfg.Images); err != nil {
+		return nil, fmt.Errorf("mirrorboard:images must be a map of component to image tag: %w", err)
+	}
+	if cfg.Images == nil {
+		cfg.Images = map[string]string{}
+	}
+
+	if cfg.Name == "" {
+		cfg.Name = ctx.Stack()
+	}
+	if cfg.Domain == "" {
+		cfg.Domain = cfg.Name + ".mirrorboards.network"
+	}
+	if cfg.Plan == "" {
+		cfg.Plan = planFree
+	}
+	switch cfg.Plan {
+	case planFree, planPro, planEnterprise:
+	default:
+		return nil, fmt.Errorf("mirrorboard:plan must be %q, %q or %q, got %q", planFree, planPro, planEnterprise, cfg.Plan)
+	}
+
+	return cfg, nil
+}
+
+// image returns the image for a component, tagged from mirrorboard:images or with fallbackTag.
+func (cfg *mirrorboardConfig) image(repository string, component string, fallbackTag string) string {
+	tag, ok := cfg.Images[component]
+	if !ok {
+		tag = fallbackTag
+	}
+
+	return repository + ":" + tag
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// ACME challenges a custom domain can be validated with.
+const (
+	challengeHttp01 = "http01"
+	challengeDns01  = "dns01"
+)
+
+// customDomainConfig is one entry of mirrorboard:customDomains.
+type customDomainConfig struct {
+	Hostname string `json:"hostname"`
+	// http01 (default) needs the domain pointed at the platform gateway; dns01 needs
+	// its zone in the Cloudflare account of the platform ACME ClusterIssuer.
+	Challenge string `json:"challenge"`
+}
+
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+func loadCustomDomains(ctx *pulumi.Context, cfg *mirrorboardConfig) ([]customDomainConfig, error) {
+	mirrorboardCfg := config.New(ctx, "mirrorboard")
+
+	var domains []customDomainConfig
+	if err := mirrorboardCfg.GetObject("customDomains", &domains); err != nil {
+		return nil, fmt.Errorf("mirrorboard:customDomains: %w", err)
+	}
+
+	seen := map[string]bool{cfg.Domain: true}
+	for i := range domains {
+		domain := &domains[i]
+
+		domain.Hostname = strings.ToLower(domain.Hostname)
+		if !hostnamePattern.MatchString(domain.Hostname) {
+			return nil, fmt.Errorf("mirrorboard:customDomains[%d].hostname must be a fully qualified hostname, got %q", i, domain.Hostname)
+		}
+		if seen[domain.Hostname] {
+			return nil, fmt.Errorf("mirrorboard:customDomains hostname %q is listed twice or is the board's domain", domain.Hostname)
+		}
+		seen[domain.Hostname] = true
+
+		if domain.Challenge == "" {
+			domain.Challenge = challengeHttp01
+		}
+		if domain.Challenge != challengeHttp01 && domain.Challenge != challengeDns01 {
+			return nil, fmt.Errorf("mirrorboard:customDomains %q challenge must be %q or %q, got %q", domain.Hostname, challengeHttp01, challengeDns01, domain.Challenge)
+		}
+	}
+
+	return domains, nil
+}
+
+// resourceName turns the hostname into a Kubernetes name.
+func (domain customDomainConfig) resourceName() string {
+	return strings.ReplaceAll(domain.Hostname, ".", "-")
+}
+
+// certificate is a cert-manager Certificate together with its live status. It is
+// registered the way apiextensions.NewCustomResource registers it, under the same type,
+// but keeps the status CustomResource does not expose.
+type certificate struct {
+	pulumi.CustomResourceState
+
+	Status pulumi.MapOutput `pulumi:"status"`
+}
+
+// certificateStatus reads the Certificate's Ready condition: "ready" once the certificate
+// is issued, otherwise the condition's reason, e.g. "DoesNotExist" or "Issuing", with its
+// message. The status is as of the last update or refresh of the stack.
+func certificateStatus(status map[string]interface{}) (string, string) {
+	conditions, _ := status["conditions"].([]interface{})
+	for _, condition := range conditions {
+		condition, _ := condition.(map[string]interface{})
+		if condition["type"] != "Ready" {
+			continue
+		}
+
+		message, _ := condition["message"].(string)
+		if condition["status"] == "True" {
+			return "ready", message
+		}
+
+		reason, _ := condition["reason"].(string)
+		if reason == "" {
+			reason = "pending"
+		}
+
+		return reason, message
+	}
+
+	return "pending", ""
+}
+
+// customDomainsArgs is what newCustomDomains routes the domains to.
+type customDomainsArgs struct {
+	NamespaceName    pulumi.StringOutput
+	GatewayName      pulumi.StringOutput
+	GatewayNamespace pulumi.StringOutput
+	// ClusterIssuer of the platform gateway stack and the Certificate label selecting
+	// its dns01 solver.
+	AcmeClusterIssuerName pulumi.StringOutput
+	AcmeChallengeLabel    pulumi.StringOutput
+	ServiceName           pulumi.StringOutput
+	ServicePort           int
+	Service               pulumi.Resource
+}
+
+// newCustomDomains issues a certificate for every custom domain from the platform ACME
+// ClusterIssuer, terminates TLS for it on a ListenerSet attached to the platform gateway and
+// routes it to the board. Whether a domain is ready is up to ACME: until the tenant's
+// DNS validates, cert-manager keeps retrying with backoff and the listener has no
+// certificate yet. It returns the CustomDomains output.
+func newCustomDomains(ctx *pulumi.Context, nsGet func(parts ...string) string, cfg *mirrorboardConfig, domains []customDomainConfig, args customDomainsArgs) (pulumi.Map, error) {
+	CustomDomains := pulumi.Map{}
+	if len(domains) == 0 {
+		return CustomDomains, nil
+	}
+
+	listeners := pulumi.Array{}
+	var certificates []pulumi.Resource
+
+	for _, domain := range domains {
+		name := domain.resourceName()
+		secretName := name + "-tls"
+
+		var certificate certificate
+		err := ctx.RegisterResource("kubernetes:cert-manager.io/v1:Certificate", nsGet("certificate", name), kubernetes.UntypedArgs{
+			"apiVersion": pulumi.String("cert-manager.io/v1"),
+			"kind":       pulumi.String("Certificate"),
+			"metadata": &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(name),
+				Namespace: args.NamespaceName,
+				Labels: args.AcmeChallengeLabel.ApplyT(func(label string) map[string]string {
+					return map[string]string{label: domain.Challenge}
+				}).(pulumi.StringMapOutput),
+			},
+			"spec": pulumi.Map{
+				"secretName": pulumi.String(secretName),
+				"dnsNames": pulumi.StringArray{
+					pulumi.String(domain.Hostname),
+				},
+				"issuerRef": pulumi.Map{
+					"name": args.AcmeClusterIssuerName,
+					"kind": pulumi.String("ClusterIssuer"),
+				},
+			},
+		}, &certificate)
+		if err != nil {
+			return nil, err
+		}
+
+		certificates = append(certificates, &certificate)
+
+		listeners = append(listeners, pulumi.Map{
+			"name":     pulumi.String(name),
+			"hostname": pulumi.String(domain.Hostname),
+			"port":     pulumi.Int(443),
+			"protocol": pulumi.String("HTTPS"),
+			"tls": pulumi.Map{
+				"mode": pulumi.String("Terminate"),
+				"certificateRefs": pulumi.Array{
+					pulumi.Map{
+						"kind": pulumi.String("Secret"),
+						"name": pulumi.String(secretName),
+					},
+				},
+			},
+		})
+
+		CustomDomains[domain.Hostname] = pulumi.Map{
+			"challenge": pulumi.String(domain.Challenge),
+			"status": certificate.Status.ApplyT(func(status map[string]interface{}) string {
+				state, _ := certificateStatus(status)
+				return state
+			}).(pulumi.StringOutput),
+			"message": certificate.Status.ApplyT(func(status map[string]interface{}) string {
+				_, message := certificateStatus(status)
+				return message
+			}).(pulumi.StringOutput),
+			"certificateName": pulumi.String(name),
+			"secretName":      pulumi.String(secretName),
+			"target":          pulumi.String(cfg.Domain),
+		}
+	}
+
+	// The platform gateway has to allow ListenerSets from tenant namespaces
+	// (spec.allowedListeners) for these listeners to be attached
+	listenerSet, err := apiextensions.NewCustomResource(ctx, nsGet("custom-domains", "listenerset"), &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("gateway.networking.x-k8s.io/v1alpha1"),
+		Kind:       pulumi.String("XListenerSet"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("custom-domains"),
+			Namespace: args.NamespaceName,
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"parentRef": pulumi.Map{
+					"name":      args.GatewayName,
+					"namespace": args.GatewayNamespace,
+					"kind":      pulumi.String("Gateway"),
+					"group":     pulumi.String("gateway.networking.k8s.io"),
+				},
+				"listeners": listeners,
+			},
+		},
+	}, pulumi.DependsOn(certificates))
+	if err != nil {
+		return nil, err
+	}
+
+	parentRefs := pulumi.Array{}
+	hostnames := pulumi.Array{}
+	for _, listener := range listeners {
+		listener := listener.(pulumi.Map)
+
+		parentRefs = append(parentRefs, pulumi.Map{
+			"name":        pulumi.String("custom-domains"),
+			"kind":        pulumi.String("XListenerSet"),
+			"group":       pulumi.String("gateway.networking.x-k8s.io"),
+			"sectionName": listener["name"],
+		})
+		hostnames = append(hostnames, listener["hostname"])
+	}
+
+	// HTTPRoute for the custom domains
+	_, err = apiextensions.NewCustomResource(ctx, nsGet("custom-domains", "httproute"), &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("gateway.networking.k8s.io/v1"),
+		Kind:       pulumi.String("HTTPRoute"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("custom-domains-httproute"),
+			Namespace: args.NamespaceName,
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"parentRefs": parentRefs,
+				"hostnames":  hostnames,
+				"rules": pulumi.Array{
+					pulumi.Map{
+						"matches": pulumi.Array{
+							pulumi.Map{
+								"path": pulumi.Map{
+									"type":  pulumi.String("PathPrefix"),
+									"value": pulumi.String("/"),
+								},
+							},
+						},
+						"backendRefs": pulumi.Array{
+							pulumi.Map{
+								"name": args.ServiceName,
+								"port": pulumi.Int(args.ServicePort),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.DependsOn([]pulumi.Resource{listenerSet, args.Service}))
+	if err != nil {
+		return nil, err
+	}
+
+	// HTTPRoute for HTTP to HTTPS redirect. ACME HTTP-01 challenge routes match
+	// their exact path and take precedence over this one
+	_, err = apiextensions.NewCustomResource(ctx, nsGet("custom-domains", "httproute-redirect"), &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("gateway.networking.k8s.io/v1"),
+		Kind:       pulumi.String("HTTPRoute"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("custom-domains-httproute-redirect"),
+			Namespace: args.NamespaceName,
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"parentRefs": pulumi.Array{
+					pulumi.Map{
+						"name":        args.GatewayName,
+						"namespace":   args.GatewayNamespace,
+						"kind":        pulumi.String("Gateway"),
+						"sectionName": pulumi.String("http"),
+					},
+				},
+				"hostnames": hostnames,
+				"rules": pulumi.Array{
+					pulumi.Map{
+						"filters": pulumi.Array{
+							pulumi.Map{
+								"type": pulumi.String("RequestRedirect"),
+								"requestRedirect": pulumi.Map{
+									"scheme":     pulumi.String("https"),
+									"statusCode": pulumi.Int(301),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return CustomDomains, nil
+}
@@ -1,20 +1,57 @@
 package main
 
 import (
+	"fmt"
+
+	"github.com/mirrorboards-go/mirrorboards-pulumi/charts"
 	"github.com/mirrorboards-go/mirrorboards-pulumi/namespace"
 
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
 	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
+// streamwavesConnectPort is the HTTP port of streamwaves-connect.
+const streamwavesConnectPort = 8080
+
+// dragonflyPort is the port the operator exposes Dragonfly's Redis protocol on.
+const dragonflyPort = 6379
+
 func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
-		ns := namespace.NewNamespace("mirrorboard", ctx.Stack())
+		cfg, err := loadMirrorboardConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		customDomains, err := loadCustomDomains(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		ns := namespace.NewNamespace("mirrorboard", cfg.Name)
+
+		// Get Gateway name from mirrorboards-platform-gateway stack
+		gatewayStack, err := pulumi.NewStackReference(ctx, "mirrorboards/mirrorboards-platform-gateway/dev", nil)
+		if err != nil {
+			return err
+		}
+
+		GatewayName := gatewayStack.GetStringOutput(pulumi.String("GatewayName"))
+		GatewayNamespace := gatewayStack.GetStringOutput(pulumi.String("GatewayNamespace"))
+		AcmeClusterIssuerName := gatewayStack.GetStringOutput(pulumi.String("AcmeClusterIssuerName"))
+		AcmeChallengeLabel := gatewayStack.GetStringOutput(pulumi.String("AcmeChallengeLabel"))
 
 		Namespace, err := corev1.NewNamespace(ctx, ns.Get("namespace"), &corev1.NamespaceArgs{
 			Metadata: &metav1.ObjectMetaArgs{
 				Name: pulumi.String(ns.Get()),
+				Labels: pulumi.StringMap{
+					"mirrorboards.network/tenant": pulumi.String(cfg.Name),
+					"mirrorboards.network/plan":   pulumi.String(cfg.Plan),
+				},
 			},
 		})
 
@@ -22,14 +59,271 @@ func main() {
 			return err
 		}
 
-		/**
+		NamespaceName := Namespace.Metadata.Name().Elem()
+
+		// Workloads are only admitted once the plan's limits are in place
+		planLimits, err := newPlanLimits(ctx, ns.Get, cfg.Plan, NamespaceName)
+		if err != nil {
+			return err
+		}
+
+		// Postgres for the content_card index
+		PostgresCluster, err := apiextensions.NewCustomResource(ctx, ns.Get("postgres"), &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("postgresql.cnpg.io/v1"),
+			Kind:       pulumi.String("Cluster"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("postgres"),
+				Namespace: NamespaceName,
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"instances": pulumi.Int(1),
+					"storage": pulumi.Map{
+						"size": pulumi.String("1Gi"),
+					},
+				},
+			},
+		}, pulumi.DependsOn(planLimits))
+
+		if err != nil {
+			return err
+		}
+
+		PostgresSecretName := pulumi.String("postgres-app")
+
+		// Dragonfly for streamwaves-connect's streams
+		Dragonfly, err := charts.NewDragonflyInstance(ctx, ns.Get("dragonfly"), &charts.NewDragonflyInstanceArgs{
+			Name:      pulumi.String("dragonfly"),
+			Namespace: NamespaceName,
+		}, pulumi.DependsOn(planLimits))
+		if err != nil {
+			return err
+		}
+
+		RedisUrl := pulumi.Sprintf("redis://dragonfly.%s.svc.cluster.local:%d", NamespaceName, dragonflyPort)
+
+		appLabels := pulumi.StringMap{
+			"app": pulumi.String("streamwaves-connect"),
+		}
+
+		// streamwaves-connect with content_card indexer
+		Deployment, err := appsv1.NewDeployment(ctx, ns.Get("streamwaves-connect", "deployment"), &appsv1.DeploymentArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("streamwaves-connect"),
+				Namespace: NamespaceName,
+				Labels:    appLabels,
+			},
+			Spec: &appsv1.DeploymentSpecArgs{
+				Replicas: pulumi.Int(1),
+				Selector: &metav1.LabelSelectorArgs{
+					MatchLabels: appLabels,
+				},
+				Template: &corev1.PodTemplateSpecArgs{
+					Metadata: &metav1.ObjectMetaArgs{
+						Labels: appLabels,
+					},
+					Spec: &corev1.PodSpecArgs{
+						Containers: corev1.ContainerArray{
+							&corev1.ContainerArgs{
+								Name:            pulumi.String("streamwaves-connect"),
+								Image:           pulumi.String(cfg.image("ghcr.io/streamwaves/streamwaves-connect", "streamwaves-connect", "main")),
+								ImagePullPolicy: pulumi.String("Always"),
+								Ports: corev1.ContainerPortArray{
+									&corev1.ContainerPortArgs{
+										ContainerPort: pulumi.Int(streamwavesConnectPort),
+										Name:          pulumi.String("http"),
+									},
+								},
+								Env: corev1.EnvVarArray{
+									&corev1.EnvVarArgs{
+										Name:  pulumi.String("PORT"),
+										Value: pulumi.String(fmt.Sprint(streamwavesConnectPort)),
+									},
+									&corev1.EnvVarArgs{
+										Name:  pulumi.String("STREAMWAVES_TENANT"),
+										Value: pulumi.String(cfg.Name),
+									},
+									&corev1.EnvVarArgs{
+										Name:  pulumi.String("STREAMWAVES_INDEXERS"),
+										Value: pulumi.String("content_card"),
+									},
+									&corev1.EnvVarArgs{
+										Name: pulumi.String("STREAMWAVES_POSTGRES_URI"),
+										ValueFrom: &corev1.EnvVarSourceArgs{
+											SecretKeyRef: &corev1.SecretKeySelectorArgs{
+												Name: PostgresSecretName,
+												Key:  pulumi.String("uri"),
+											},
+										},
+									},
+									&corev1.EnvVarArgs{
+										Name:  pulumi.String("STREAMWAVES_REDIS_URL"),
+										Value: RedisUrl,
+									},
+								},
+								Resources: &corev1.ResourceRequirementsArgs{
+									Requests: pulumi.StringMap{
+										"memory": pulumi.String("128Mi"),
+										"cpu":    pulumi.String("100m"),
+									},
+									Limits: pulumi.StringMap{
+										"memory": pulumi.String("512Mi"),
+										"cpu":    pulumi.String("500m"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{PostgresCluster, Dragonfly}))
+
+		if err != nil {
+			return err
+		}
+
+		Service, err := corev1.NewService(ctx, ns.Get("streamwaves-connect", "service"), &corev1.ServiceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("streamwaves-connect"),
+				Namespace: NamespaceName,
+				Labels:    appLabels,
+			},
+			Spec: &corev1.ServiceSpecArgs{
+				Selector: appLabels,
+				Ports: corev1.ServicePortArray{
+					&corev1.ServicePortArgs{
+						Name:       pulumi.String("http"),
+						Port:       pulumi.Int(streamwavesConnectPort),
+						TargetPort: pulumi.Int(streamwavesConnectPort),
+						Protocol:   pulumi.String("TCP"),
+					},
+				},
+				Type: pulumi.String("ClusterIP"),
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{Deployment}))
 
-		Create stack with:
-			- namespace
-			- streamwaves-connect with content_card indexer
-		*/
+		if err != nil {
+			return err
+		}
 
-		ctx.Export("NamespaceName", Namespace.Metadata.Name())
+		// HTTPRoute for the board (<tenant>.mirrorboards.network)
+		_, err = apiextensions.NewCustomResource(ctx, ns.Get("streamwaves-connect", "httproute"), &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("gateway.networking.k8s.io/v1"),
+			Kind:       pulumi.String("HTTPRoute"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("streamwaves-connect-httproute"),
+				Namespace: NamespaceName,
+				Annotations: pulumi.StringMap{
+					"external-dns.alpha.kubernetes.io/hostname": pulumi.String(cfg.Domain),
+				},
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"parentRefs": pulumi.Array{
+						pulumi.Map{
+							"name":        GatewayName,
+							"namespace":   GatewayNamespace,
+							"kind":        pulumi.String("Gateway"),
+							"sectionName": pulumi.String("https"),
+						},
+					},
+					"hostnames": pulumi.Array{
+						pulumi.String(cfg.Domain),
+					},
+					"rules": pulumi.Array{
+						pulumi.Map{
+							"matches": pulumi.Array{
+								pulumi.Map{
+									"path": pulumi.Map{
+										"type":  pulumi.String("PathPrefix"),
+										"value": pulumi.String("/"),
+									},
+								},
+							},
+							"backendRefs": pulumi.Array{
+								pulumi.Map{
+									"name": Service.Metadata.Name(),
+									"port": pulumi.Int(streamwavesConnectPort),
+								},
+							},
+						},
+					},
+				},
+			},
+		}, pulumi.DependsOn([]pulumi.Resource{Service}))
+
+		if err != nil {
+			return err
+		}
+
+		// HTTPRoute for HTTP to HTTPS redirect
+		_, err = apiextensions.NewCustomResource(ctx, ns.Get("streamwaves-connect", "httproute-redirect"), &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("gateway.networking.k8s.io/v1"),
+			Kind:       pulumi.String("HTTPRoute"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("streamwaves-connect-httproute-redirect"),
+				Namespace: NamespaceName,
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"parentRefs": pulumi.Array{
+						pulumi.Map{
+							"name":        GatewayName,
+							"namespace":   GatewayNamespace,
+							"kind":        pulumi.String("Gateway"),
+							"sectionName": pulumi.String("http"),
+						},
+					},
+					"hostnames": pulumi.Array{
+						pulumi.String(cfg.Domain),
+					},
+					"rules": pulumi.Array{
+						pulumi.Map{
+							"filters": pulumi.Array{
+								pulumi.Map{
+									"type": pulumi.String("RequestRedirect"),
+									"requestRedirect": pulumi.Map{
+										"scheme":     pulumi.String("https"),
+										"statusCode": pulumi.Int(301),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		CustomDomains, err := newCustomDomains(ctx, ns.Get, cfg, customDomains, customDomainsArgs{
+			NamespaceName:         NamespaceName,
+			GatewayName:           GatewayName,
+			GatewayNamespace:      GatewayNamespace,
+			AcmeClusterIssuerName: AcmeClusterIssuerName,
+			AcmeChallengeLabel:    AcmeChallengeLabel,
+			ServiceName:           Service.Metadata.Name().Elem(),
+			ServicePort:           streamwavesConnectPort,
+			Service:               Service,
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("NamespaceName", NamespaceName)
+		ctx.Export("Tenant", pulumi.String(cfg.Name))
+		ctx.Export("Plan", pulumi.String(cfg.Plan))
+		ctx.Export("Domain", pulumi.String(cfg.Domain))
+		ctx.Export("PostgresClusterName", PostgresCluster.Metadata.Name())
+		ctx.Export("PostgresSecretName", PostgresSecretName)
+		ctx.Export("RedisUrl", RedisUrl)
+		ctx.Export("CustomDomains", CustomDomains)
+		ctx.Export("Endpoints", pulumi.Map{
+			"url":                pulumi.String("https://" + cfg.Domain),
+			"streamwavesConnect": pulumi.Sprintf("http://%s.%s.svc.cluster.local:%d", Service.Metadata.Name().Elem(), NamespaceName, streamwavesConnectPort),
+		})
 
 		return nil
 	})
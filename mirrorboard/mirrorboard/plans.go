@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// planQuota is what a tenant namespace may consume in total on a plan.
+type planQuota struct {
+	RequestsCpu    string
+	RequestsMemory string
+	LimitsCpu      string
+	LimitsMemory   string
+	Pvcs           int
+	Storage        string
+}
+
+// planQuotas leave room for the board itself (Postgres, Dragonfly and
+// streamwaves-connect) on every plan.
+var planQuotas = map[string]planQuota{
+	planFree: {
+		RequestsCpu:    "1",
+		RequestsMemory: "2Gi",
+		LimitsCpu:      "2",
+		LimitsMemory:   "4Gi",
+		Pvcs:           4,
+		Storage:        "10Gi",
+	},
+	planPro: {
+		RequestsCpu:    "2",
+		RequestsMemory: "4Gi",
+		LimitsCpu:      "4",
+		LimitsMemory:   "8Gi",
+		Pvcs:           10,
+		Storage:        "50Gi",
+	},
+	planEnterprise: {
+		RequestsCpu:    "8",
+		RequestsMemory: "16Gi",
+		LimitsCpu:      "16",
+		LimitsMemory:   "32Gi",
+		Pvcs:           30,
+		Storage:        "500Gi",
+	},
+}
+
+// newPlanLimits caps the tenant namespace at its plan's quota. The LimitRange gives
+// containers without resources defaults, since the quota rejects pods that set none.
+func newPlanLimits(ctx *pulumi.Context, nsGet func(parts ...string) string, plan string, namespaceName pulumi.StringOutput) ([]pulumi.Resource, error) {
+	quota, ok := planQuotas[plan]
+	if !ok {
+		return nil, fmt.Errorf("no quota for plan %q", plan)
+	}
+
+	resourceQuota, err := corev1.NewResourceQuota(ctx, nsGet("resource-quota"), &corev1.ResourceQuotaArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("plan-" + plan),
+			Namespace: namespaceName,
+		},
+		Spec: &corev1.ResourceQuotaSpecArgs{
+			Hard: pulumi.StringMap{
+				"requests.cpu":           pulumi.String(quota.RequestsCpu),
+				"requests.memory":        pulumi.String(quota.RequestsMemory),
+				"limits.cpu":             pulumi.String(quota.LimitsCpu),
+				"limits.memory":          pulumi.String(quota.LimitsMemory),
+				"persistentvolumeclaims": pulumi.String(fmt.Sprint(quota.Pvcs)),
+				"requests.storage":       pulumi.String(quota.Storage),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	limitRange, err := corev1.NewLimitRange(ctx, nsGet("limit-range"), &corev1.LimitRangeArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("plan-" + plan),
+			Namespace: namespaceName,
+		},
+		Spec: &corev1.LimitRangeSpecArgs{
+			Limits: corev1.LimitRangeItemArray{
+				&corev1.LimitRangeItemArgs{
+					Type: pulumi.String("Container"),
+					DefaultRequest: pulumi.StringMap{
+						"cpu":    pulumi.String("100m"),
+						"memory": pulumi.String("128Mi"),
+					},
+					Default: pulumi.StringMap{
+						"cpu":    pulumi.String("500m"),
+						"memory": pulumi.String("512Mi"),
+					},
+					// No single container may take the whole namespace
+					Max: pulumi.StringMap{
+						"cpu":    pulumi.String(quota.LimitsCpu),
+						"memory": pulumi.String(quota.LimitsMemory),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []pulumi.Resource{resourceQuota, limitRange}, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Certificates labelled acmeChallengeLabel=dns01 are validated over Cloudflare DNS,
+// every other one over HTTP-01 on the platform gateway.
+const (
+	acmeClusterIssuerName = "mirrorboards-acme"
+	acmeChallengeLabel    = "mirrorboards.network/acme-challenge"
+)
+
+// newAcmeClusterIssuer creates the Let's Encrypt ClusterIssuer tenant certificates are
+// issued from. cert-manager reads a ClusterIssuer's secrets from its own namespace, so
+// the Cloudflare token lives there once instead of in every tenant namespace.
+func newAcmeClusterIssuer(ctx *pulumi.Context, gatewayName string, gatewayNamespace string) error {
+	gatewayCfg := config.New(ctx, "gateway")
+
+	acmeEmail := gatewayCfg.Get("acmeEmail")
+	if acmeEmail == "" {
+		acmeEmail = "certificates@mirrorboards.network"
+	}
+
+	certManagerNamespace := gatewayCfg.Get("certManagerNamespace")
+	if certManagerNamespace == "" {
+		certManagerNamespace = "cert-manager"
+	}
+
+	cloudflareCfg := config.New(ctx, "cloudflare")
+
+	CloudflareToken, err := cloudflareCfg.TrySecret("token")
+	if err != nil {
+		return fmt.Errorf("cloudflare:token is required for dns01 certificates: %w", err)
+	}
+
+	cloudflareSecret, err := corev1.NewSecret(ctx, "mirrorboards-platform-gateway-cloudflare-api-token", &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("cloudflare-api-token"),
+			Namespace: pulumi.String(certManagerNamespace),
+		},
+		StringData: pulumi.StringMap{
+			"api-token": CloudflareToken,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = apiextensions.NewCustomResource(ctx, "mirrorboards-platform-gateway-acme-cluster-issuer", &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("cert-manager.io/v1"),
+		Kind:       pulumi.String("ClusterIssuer"),
+		Metadata: &metav1.ObjectMetaArgs{
+			Name: pulumi.String(acmeClusterIssuerName),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": pulumi.Map{
+				"acme": pulumi.Map{
+					"server": pulumi.String("https://acme-v02.api.letsencrypt.org/directory"),
+					"email":  pulumi.String(acmeEmail),
+					"privateKeySecretRef": pulumi.Map{
+						"name": pulumi.String(acmeClusterIssuerName + "-account-key"),
+					},
+					// cert-manager picks the solver with the most specific selector, so the
+					// labelled one wins over the catch-all HTTP-01 solver
+					"solvers": pulumi.Array{
+						pulumi.Map{
+							"http01": pulumi.Map{
+								"gatewayHTTPRoute": pulumi.Map{
+									"parentRefs": pulumi.Array{
+										pulumi.Map{
+											"name":        pulumi.String(gatewayName),
+											"namespace":   pulumi.String(gatewayNamespace),
+											"kind":        pulumi.String("Gateway"),
+											"sectionName": pulumi.String(sectionHttp),
+										},
+									},
+								},
+							},
+						},
+						pulumi.Map{
+							"selector": pulumi.Map{
+								"matchLabels": pulumi.Map{
+									acmeChallengeLabel: pulumi.String("dns01"),
+								},
+							},
+							"dns01": pulumi.Map{
+								"cloudflare": pulumi.Map{
+									"apiTokenSecretRef": pulumi.Map{
+										"name": pulumi.String("cloudflare-api-token"),
+										"key":  pulumi.String("api-token"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, pulumi.DependsOn([]pulumi.Resource{cloudflareSecret}))
+	if err != nil {
+		return err
+	}
+
+	ctx.Export("AcmeClusterIssuerName", pulumi.String(acmeClusterIssuerName))
+	ctx.Export("AcmeChallengeLabel", pulumi.String(acmeChallengeLabel))
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// Listeners of the platform gateway that routes attach to by sectionName.
+const (
+	sectionHttps = "https"
+	sectionHttp  = "http"
+)
+
+// The platform gateway is the Istio ingress Gateway the cluster add-on runs. This
+// stack does not manage it; it publishes where it is, so host stacks reference
+// it through GatewayName and GatewayNamespace instead of hardcoding both. It also
+// owns the ACME ClusterIssuer that certificates for hosts on the gateway come from.
+func main() {
+	pulumi.Run(func(ctx *pulumi.Context) error {
+		gatewayCfg := config.New(ctx, "gateway")
+
+		GatewayName := gatewayCfg.Get("name")
+		if GatewayName == "" {
+			GatewayName = "mirrorboards-platform-gateway"
+		}
+
+		GatewayNamespace := gatewayCfg.Get("namespace")
+		if GatewayNamespace == "" {
+			GatewayNamespace = "aks-istio-ingress"
+		}
+
+		ctx.Export("GatewayName", pulumi.String(GatewayName))
+		ctx.Export("GatewayNamespace", pulumi.String(GatewayNamespace))
+		ctx.Export("HttpsSectionName", pulumi.String(sectionHttps))
+		ctx.Export("HttpSectionName", pulumi.String(sectionHttp))
+
+		return newAcmeClusterIssuer(ctx, GatewayName, GatewayNamespace)
+	})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"github.com/mirrorboards-go/mirrorboards-pulumi/namespace"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// tenantVerbs is what Pulumi needs to create, refresh, update and delete a resource.
+var tenantVerbs = pulumi.StringArray{
+	pulumi.String("get"),
+	pulumi.String("list"),
+	pulumi.String("watch"),
+	pulumi.String("create"),
+	pulumi.String("update"),
+	pulumi.String("patch"),
+	pulumi.String("delete"),
+}
+
+// tenantRules is what operator-run tenant stacks manage inside their namespace: the
+// board and the namespace object itself. It is only ever bound per tenant namespace,
+// by the boards-notary stacks, never cluster-wide.
+var tenantRules = rbacv1.PolicyRuleArray{
+	&rbacv1.PolicyRuleArgs{
+		ApiGroups: pulumi.StringArray{pulumi.String("")},
+		Resources: pulumi.StringArray{
+			pulumi.String("namespaces"),
+			pulumi.String("secrets"),
+			pulumi.String("services"),
+			pulumi.String("serviceaccounts"),
+			pulumi.String("configmaps"),
+			pulumi.String("resourcequotas"),
+			pulumi.String("limitranges"),
+		},
+		Verbs: tenantVerbs,
+	},
+	&rbacv1.PolicyRuleArgs{
+		ApiGroups: pulumi.StringArray{pulumi.String("apps")},
+		Resources: pulumi.StringArray{pulumi.String("deployments"), pulumi.String("statefulsets")},
+		Verbs:     tenantVerbs,
+	},
+	&rbacv1.PolicyRuleArgs{
+		ApiGroups: pulumi.StringArray{pulumi.String("postgresql.cnpg.io")},
+		Resources: pulumi.StringArray{pulumi.String("clusters")},
+		Verbs:     tenantVerbs,
+	},
+	&rbacv1.PolicyRuleArgs{
+		ApiGroups: pulumi.StringArray{pulumi.String("dragonflydb.io")},
+		Resources: pulumi.StringArray{pulumi.String("dragonflies")},
+		Verbs:     tenantVerbs,
+	},
+	&rbacv1.PolicyRuleArgs{
+		ApiGroups: pulumi.StringArray{pulumi.String("cert-manager.io")},
+		Resources: pulumi.StringArray{pulumi.String("certificates")},
+		Verbs:     tenantVerbs,
+	},
+	&rbacv1.PolicyRuleArgs{
+		ApiGroups: pulumi.StringArray{pulumi.String("gateway.networking.k8s.io")},
+		Resources: pulumi.StringArray{pulumi.String("httproutes")},
+		Verbs:     tenantVerbs,
+	},
+	&rbacv1.PolicyRuleArgs{
+		ApiGroups: pulumi.StringArray{pulumi.String("gateway.networking.x-k8s.io")},
+		Resources: pulumi.StringArray{pulumi.String("xlistenersets")},
+		Verbs:     tenantVerbs,
+	},
+}
+
+func main() {
+	pulumi.Run(func(ctx *pulumi.Context) error {
+		ns := namespace.NewNamespace("mirrorboards", "platform")
+		stacksCfg := config.New(ctx, "stacks")
+
+		GitUrl := stacksCfg.Require("gitUrl")
+
+		GitBranch := stacksCfg.Get("branch")
+		if GitBranch == "" {
+			GitBranch = "main"
+		}
+
+		GitInterval := stacksCfg.Get("interval")
+		if GitInterval == "" {
+			GitInterval = "1m"
+		}
+
+		// Secret with credentials for a private repository, optional
+		GitSecretName := stacksCfg.Get("gitSecretName")
+
+		Namespace, err := corev1.NewNamespace(ctx, ns.Get("pulumi-stacks", "namespace"), &corev1.NamespaceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String("pulumi-stacks"),
+			},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		NamespaceName := Namespace.Metadata.Name().Elem()
+
+		gitRepositorySpec := pulumi.Map{
+			"url":      pulumi.String(GitUrl),
+			"interval": pulumi.String(GitInterval),
+			"ref": pulumi.Map{
+				"branch": pulumi.String(GitBranch),
+			},
+		}
+		if GitSecretName != "" {
+			gitRepositorySpec["secretRef"] = pulumi.Map{
+				"name": pulumi.String(GitSecretName),
+			}
+		}
+
+		GitRepository, err := apiextensions.NewCustomResource(ctx, ns.Get("pulumi-stacks", "git-repository"), &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("source.toolkit.fluxcd.io/v1"),
+			Kind:       pulumi.String("GitRepository"),
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("mirrorboards-stacks"),
+				Namespace: NamespaceName,
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": gitRepositorySpec,
+			},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		ServiceAccount, err := corev1.NewServiceAccount(ctx, ns.Get("pulumi-stacks", "service-account"), &corev1.ServiceAccountArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String("pulumi"),
+				Namespace: NamespaceName,
+			},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		serviceAccountSubject := rbacv1.SubjectArray{
+			&rbacv1.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      ServiceAccount.Metadata.Name().Elem(),
+				Namespace: NamespaceName,
+			},
+		}
+
+		// Workspace pods authenticate the operator with TokenReviews
+		_, err = rbacv1.NewClusterRoleBinding(ctx, ns.Get("pulumi-stacks", "auth-delegator"), &rbacv1.ClusterRoleBindingArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String("pulumi-stacks:pulumi:auth-delegator"),
+			},
+			RoleRef: &rbacv1.RoleRefArgs{
+				ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+				Kind:     pulumi.String("ClusterRole"),
+				Name:     pulumi.String("system:auth-delegator"),
+			},
+			Subjects: serviceAccountSubject,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		// The only cluster-scoped grant: creating a tenant namespace. Everything in it,
+		// the namespace object included, comes from the tenant RoleBinding
+		NamespacesClusterRole, err := rbacv1.NewClusterRole(ctx, ns.Get("pulumi-stacks", "namespaces-cluster-role"), &rbacv1.ClusterRoleArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String("pulumi-stacks:tenant-namespaces"),
+			},
+			Rules: rbacv1.PolicyRuleArray{
+				&rbacv1.PolicyRuleArgs{
+					ApiGroups: pulumi.StringArray{pulumi.String("")},
+					Resources: pulumi.StringArray{pulumi.String("namespaces")},
+					Verbs:     pulumi.StringArray{pulumi.String("create")},
+				},
+			},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		_, err = rbacv1.NewClusterRoleBinding(ctx, ns.Get("pulumi-stacks", "namespaces-cluster-role-binding"), &rbacv1.ClusterRoleBindingArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String("pulumi-stacks:pulumi:tenant-namespaces"),
+			},
+			RoleRef: &rbacv1.RoleRefArgs{
+				ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+				Kind:     pulumi.String("ClusterRole"),
+				Name:     NamespacesClusterRole.Metadata.Name().Elem(),
+			},
+			Subjects: serviceAccountSubject,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		// Bound in each tenant namespace by the stack that creates the tenant
+		ClusterRole, err := rbacv1.NewClusterRole(ctx, ns.Get("pulumi-stacks", "cluster-role"), &rbacv1.ClusterRoleArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String("pulumi-stacks:tenant"),
+			},
+			Rules: tenantRules,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		// Placeholders for the credentials stacks run with. The values are filled in
+		// out of band and left alone by later updates
+		placeholder := func(name string, key string) (*corev1.Secret, error) {
+			return corev1.NewSecret(ctx, ns.Get("pulumi-stacks", name), &corev1.SecretArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:      pulumi.String(name),
+					Namespace: NamespaceName,
+				},
+				StringData: pulumi.StringMap{
+					key: pulumi.String(""),
+				},
+			}, pulumi.IgnoreChanges([]string{"data", "stringData"}))
+		}
+
+		AccessTokenSecret, err := placeholder("pulumi-api-secret", "accessToken")
+		if err != nil {
+			return err
+		}
+
+		PassphraseSecret, err := placeholder("pulumi-config-passphrase", "passphrase")
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("NamespaceName", NamespaceName)
+		ctx.Export("GitRepositoryName", GitRepository.Metadata.Name())
+		ctx.Export("ServiceAccountName", ServiceAccount.Metadata.Name())
+		ctx.Export("TenantClusterRoleName", ClusterRole.Metadata.Name())
+		ctx.Export("AccessTokenSecretName", AccessTokenSecret.Metadata.Name())
+		ctx.Export("AccessTokenSecretKey", pulumi.String("accessToken"))
+		ctx.Export("PassphraseSecretName", PassphraseSecret.Metadata.Name())
+		ctx.Export("PassphraseSecretKey", pulumi.String("passphrase"))
+
+		return nil
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+)
+
+// project is a Pulumi project found under the root and the projects its program
+// reads through stack references.
+type project struct {
+	Name string
+	Dir  string
+	// Discovered projects referenced by the program.
+	Dependencies []string
+	// Referenced projects that live outside the root, deployed by someone else.
+	External []string
+}
+
+// discoverProjects finds every Pulumi.yaml below root. Hidden directories such as
+// .legacy and .git are skipped.
+func discoverProjects(root string) ([]*project, error) {
+	var projects []*project
+	byName := map[string]*project{}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if path != root && (strings.HasPrefix(entry.Name(), ".") || entry.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if entry.Name() != "Pulumi.yaml" {
+			return nil
+		}
+
+		definition, err := workspace.LoadProject(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		dir := filepath.Dir(path)
+		name := string(definition.Name)
+
+		if existing, ok := byName[name]; ok {
+			return fmt.Errorf("project %q is defined in both %s and %s", name, existing.Dir, dir)
+		}
+
+		references, err := stackReferences(dir)
+		if err != nil {
+			return err
+		}
+
+		p := &project{Name: name, Dir: dir, Dependencies: references}
+		projects = append(projects, p)
+		byName[name] = p
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Only references to projects found here are ordered; the rest are external
+	for _, p := range projects {
+		var dependencies []string
+		for _, reference := range p.Dependencies {
+			if reference == p.Name {
+				continue
+			}
+			if _, ok := byName[reference]; ok {
+				dependencies = append(dependencies, reference)
+			} else {
+				p.External = append(p.External, reference)
+			}
+		}
+		p.Dependencies = dependencies
+	}
+
+	return projects, nil
+}
+
+// stackReferences returns the projects a program references, read from the
+// "<org>/<project>/<stack>" literals passed to pulumi.NewStackReference in its Go files.
+func stackReferences(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var references []string
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) < 2 {
+				return true
+			}
+
+			selector, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || selector.Sel.Name != "NewStackReference" {
+				return true
+			}
+
+			literal, ok := call.Args[1].(*ast.BasicLit)
+			if !ok || literal.Kind != token.STRING {
+				return true
+			}
+
+			value, err := strconv.Unquote(literal.Value)
+			if err != nil {
+				return true
+			}
+
+			parts := strings.Split(value, "/")
+			if len(parts) == 3 && !slices.Contains(references, parts[1]) {
+				references = append(references, parts[1])
+			}
+
+			return true
+		})
+	}
+
+	slices.Sort(references)
+
+	return references, nil
+}
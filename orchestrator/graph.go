@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// graph orders projects so that every project comes after the projects it references.
+type graph struct {
+	projects map[string]*project
+	// dependents is the reverse of project.Dependencies.
+	dependents map[string][]string
+}
+
+func newGraph(projects []*project) (*graph, error) {
+	g := &graph{
+		projects:   map[string]*project{},
+		dependents: map[string][]string{},
+	}
+
+	for _, p := range projects {
+		g.projects[p.Name] = p
+	}
+	for _, p := range projects {
+		for _, dependency := range p.Dependencies {
+			g.dependents[dependency] = append(g.dependents[dependency], p.Name)
+		}
+	}
+
+	if _, err := g.order(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// order returns the projects dependencies first, alphabetically within each level.
+func (g *graph) order() ([]string, error) {
+	remaining := map[string]int{}
+	var ready []string
+
+	for name, p := range g.projects {
+		remaining[name] = len(p.Dependencies)
+		if len(p.Dependencies) == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	var order []string
+	for len(ready) > 0 {
+		slices.Sort(ready)
+
+		var next []string
+		for _, name := range ready {
+			order = append(order, name)
+
+			for _, dependent := range g.dependents[name] {
+				remaining[dependent]--
+				if remaining[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		ready = next
+	}
+
+	if len(order) != len(g.projects) {
+		var cycle []string
+		for name, count := range remaining {
+			if count > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		slices.Sort(cycle)
+
+		return nil, fmt.Errorf("stack references form a cycle between %s", strings.Join(cycle, ", "))
+	}
+
+	return order, nil
+}
+
+// reversed is the graph destroy runs on: dependents first.
+func (g *graph) reversed() *graph {
+	projects := map[string]*project{}
+	for name, p := range g.projects {
+		projects[name] = &project{
+			Name:         p.Name,
+			Dir:          p.Dir,
+			Dependencies: slices.Clone(g.dependents[name]),
+			External:     p.External,
+		}
+	}
+
+	dependents := map[string][]string{}
+	for name, p := range g.projects {
+		dependents[name] = slices.Clone(p.Dependencies)
+	}
+
+	return &graph{projects: projects, dependents: dependents}
+}
+
+// subset keeps the named projects together with every project they depend on, and
+// drops the ones in skip. Dependencies on skipped projects are treated as met.
+func (g *graph) subset(only []string, skip []string) (*graph, error) {
+	skipped := map[string]bool{}
+	for _, name := range skip {
+		if _, ok := g.projects[name]; !ok {
+			return nil, fmt.Errorf("no project named %q", name)
+		}
+		skipped[name] = true
+	}
+
+	keep := map[string]bool{}
+
+	var visit func(name string)
+	visit = func(name string) {
+		if keep[name] || skipped[name] {
+			return
+		}
+		keep[name] = true
+		for _, dependency := range g.projects[name].Dependencies {
+			visit(dependency)
+		}
+	}
+
+	if len(only) == 0 {
+		for name := range g.projects {
+			visit(name)
+		}
+	}
+
+	for _, name := range only {
+		if _, ok := g.projects[name]; !ok {
+			return nil, fmt.Errorf("no project named %q", name)
+		}
+		visit(name)
+	}
+
+	var projects []*project
+	for name := range keep {
+		p := *g.projects[name]
+
+		p.Dependencies = slices.DeleteFunc(slices.Clone(p.Dependencies), func(dependency string) bool {
+			return !keep[dependency]
+		})
+		projects = append(projects, &p)
+	}
+
+	return newGraph(projects)
+}
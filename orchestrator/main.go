@@ -0,0 +1,171 @@
+// Command orchestrator runs pulumi preview, up or destroy over every Pulumi project
+// in the repository, in the order their stack references imply.
+//
+//	orchestrator [flags] preview|up|destroy
+//
+// Projects are discovered from their Pulumi.yaml, and a project depends on every
+// project its Go program passes to pulumi.NewStackReference. preview and up run
+// dependencies first, destroy runs dependents first. Independent stacks run in parallel.
+// Projects the Pulumi Operator deploys are left out unless -include-operator is set.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// operatorProjects are deployed by the Pulumi Operator from boards-notary, one stack
+// per tenant, so the orchestrator leaves them out unless -include-operator is set.
+var operatorProjects = []string{"mirrorboard"}
+
+// listFlag collects a comma separated or repeated flag.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	root := flag.String("root", ".", "directory to discover Pulumi projects in")
+	org := flag.String("org", "mirrorboards", "Pulumi organization of the stacks")
+	stack := flag.String("stack", "dev", "stack to run in every project")
+	parallel := flag.Int("parallel", 4, "number of stacks to run at once")
+	dryRun := flag.Bool("dry-run", false, "print the order stacks would run in and exit")
+
+	var only, skip listFlag
+	flag.Var(&only, "only", "run only these projects, with what they depend on (dependents for destroy)")
+	flag.Var(&skip, "skip", "leave these projects out")
+	includeOperator := flag.Bool("include-operator", false, "also run projects the Pulumi Operator deploys: "+strings.Join(operatorProjects, ", "))
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: orchestrator [flags] preview|up|destroy\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	operation := flag.Arg(0)
+	if operation != operationPreview && operation != operationUp && operation != operationDestroy {
+		fmt.Fprintf(os.Stderr, "unknown operation %q\n", operation)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *parallel < 1 {
+		fmt.Fprintf(os.Stderr, "-parallel must be at least 1\n")
+		os.Exit(2)
+	}
+
+	if err := run(operation, *root, *org, *stack, *parallel, *dryRun, *includeOperator, only, skip); err != nil {
+		fmt.Fprintf(os.Stderr, "orchestrator: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(operation string, root string, org string, stack string, parallel int, dryRun bool, includeOperator bool, only []string, skip []string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	projects, err := discoverProjects(root)
+	if err != nil {
+		return err
+	}
+
+	g, err := newGraph(projects)
+	if err != nil {
+		return err
+	}
+
+	// Destroy walks the graph from the other end
+	if operation == operationDestroy {
+		g = g.reversed()
+	}
+
+	// Naming an operator project in -only still runs it
+	if !includeOperator {
+		for _, name := range operatorProjects {
+			if _, ok := g.projects[name]; ok && !slices.Contains(only, name) {
+				skip = append(skip, name)
+			}
+		}
+	}
+
+	g, err = g.subset(only, skip)
+	if err != nil {
+		return err
+	}
+
+	order, err := g.order()
+	if err != nil {
+		return err
+	}
+
+	for i, name := range order {
+		p := g.projects[name]
+		relative, _ := filepath.Rel(root, p.Dir)
+
+		line := fmt.Sprintf("%2d. %s (%s)", i+1, name, relative)
+		if len(p.Dependencies) > 0 {
+			line += " after " + strings.Join(p.Dependencies, ", ")
+		}
+		if len(p.External) > 0 {
+			line += ", external " + strings.Join(p.External, ", ")
+		}
+		fmt.Println(line)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	r := &runner{
+		operation: operation,
+		org:       org,
+		stack:     stack,
+		parallel:  parallel,
+		out:       os.Stdout,
+	}
+
+	var failed, skipped []string
+	for _, result := range r.run(ctx, g) {
+		switch result.Status {
+		case statusFailed:
+			failed = append(failed, result.Name)
+		case statusSkipped:
+			skipped = append(skipped, result.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed for %s; skipped %d dependent stacks: %s",
+			operation, strings.Join(failed, ", "), len(skipped), strings.Join(skipped, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+)
+
+// Operations the orchestrator runs on every stack.
+const (
+	operationPreview = "preview"
+	operationUp      = "up"
+	operationDestroy = "destroy"
+)
+
+// Outcome of a project in a run.
+const (
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+	// A project it depends on failed or was skipped.
+	statusSkipped = "skipped"
+)
+
+type runResult struct {
+	Name   string
+	Status string
+	Err    error
+}
+
+// runner runs one operation over a graph, starting a project as soon as everything
+// it depends on has succeeded and never more than parallel at once.
+type runner struct {
+	operation string
+	// Stack in every project, e.g. dev, selected as <org>/<stack>.
+	org      string
+	stack    string
+	parallel int
+	out      io.Writer
+	outMu    sync.Mutex
+}
+
+func (r *runner) run(ctx context.Context, g *graph) []runResult {
+	remaining := map[string]int{}
+	blocked := map[string]bool{}
+	var ready []string
+
+	for name, p := range g.projects {
+		remaining[name] = len(p.Dependencies)
+		if len(p.Dependencies) == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	finished := make(chan runResult)
+	var results []runResult
+	running := 0
+
+	// finish records a result and releases the projects waiting on it. Dependents of
+	// a project that did not succeed are skipped without running
+	var finish func(result runResult)
+	finish = func(result runResult) {
+		results = append(results, result)
+
+		for _, dependent := range g.dependents[result.Name] {
+			if result.Status != statusSucceeded {
+				blocked[dependent] = true
+			}
+
+			remaining[dependent]--
+			if remaining[dependent] > 0 {
+				continue
+			}
+
+			if blocked[dependent] {
+				r.printf(dependent, "skipped, a stack it depends on did not succeed\n")
+				finish(runResult{Name: dependent, Status: statusSkipped})
+			} else {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	for len(results) < len(g.projects) {
+		for len(ready) > 0 && running < r.parallel {
+			p := g.projects[ready[0]]
+			ready = ready[1:]
+			running++
+
+			go func() {
+				err := r.runProject(ctx, p)
+
+				status := statusSucceeded
+				if err != nil {
+					status = statusFailed
+				}
+				finished <- runResult{Name: p.Name, Status: status, Err: err}
+			}()
+		}
+
+		result := <-finished
+		running--
+
+		if result.Err != nil {
+			r.printf(result.Name, "failed: %v\n", result.Err)
+		} else {
+			r.printf(result.Name, "done\n")
+		}
+
+		finish(result)
+	}
+
+	return results
+}
+
+// runProject runs the operation on the project's stack from its directory.
+func (r *runner) runProject(ctx context.Context, p *project) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.printf(p.Name, "%s %s/%s in %s\n", r.operation, r.org, r.stack, p.Dir)
+
+	// Only up may create the stack; preview and destroy of a stack that does not
+	// exist yet fail instead of leaving an empty one behind
+	stackName := r.org + "/" + r.stack
+
+	var stack auto.Stack
+	var err error
+	if r.operation == operationUp {
+		stack, err = auto.UpsertStackLocalSource(ctx, stackName, p.Dir)
+	} else {
+		stack, err = auto.SelectStackLocalSource(ctx, stackName, p.Dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	out := &prefixWriter{runner: r, name: p.Name}
+	defer out.Flush()
+
+	switch r.operation {
+	case operationPreview:
+		_, err = stack.Preview(ctx, optpreview.ProgressStreams(out))
+	case operationUp:
+		_, err = stack.Up(ctx, optup.ProgressStreams(out))
+	case operationDestroy:
+		_, err = stack.Destroy(ctx, optdestroy.ProgressStreams(out))
+	default:
+		err = fmt.Errorf("unknown operation %q", r.operation)
+	}
+
+	return err
+}
+
+// printf writes one line of output for a project.
+func (r *runner) printf(name string, format string, args ...any) {
+	r.outMu.Lock()
+	defer r.outMu.Unlock()
+
+	fmt.Fprintf(r.out, "[%s] "+format, append([]any{name}, args...)...)
+}
+
+// prefixWriter prefixes every line of a stack's progress with its project, so the
+// output of stacks running in parallel stays readable.
+type prefixWriter struct {
+	runner *runner
+	name   string
+	buffer bytes.Buffer
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buffer.Write(p)
+
+	for {
+		line, err := w.buffer.ReadBytes('\n')
+		if err != nil {
+			// Keep the partial line for the next write
+			w.buffer.Write(line)
+			break
+		}
+		w.runner.printf(w.name, "%s", line)
+	}
+
+	return len(p), nil
+}
+
+// Flush writes out a last line without a newline.
+func (w *prefixWriter) Flush() {
+	if w.buffer.Len() > 0 {
+		w.runner.printf(w.name, "%s\n", w.buffer.String())
+		w.buffer.Reset()
+	}
+}